@@ -1,54 +1,164 @@
 // Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
 // SPDX-License-Identifier: ISC
 
-// A simple in-memory cache store.
+// Simple cache stores.
 package cache
 
-import "time"
+import (
+	"container/list"
+	"time"
+)
+
+// A key-value store whose items expire.
+//
+// Get must return an empty []byte if the key does not exist or if
+// the item corresponding to the key has expired.
+//
+// A store may keep expired items for a grace window; GetStale returns
+// them along with the time they expired. It returns an empty []byte
+// if the key does not exist or if the item was dropped.
+type Store interface {
+	Get(key string) []byte
+	GetStale(key string) ([]byte, time.Time)
+	Set(key string, value []byte, expires time.Time)
+	Delete(key string)
+}
+
+// A byte-level Cache is a Store.
+var _ Store = (*Cache[string, []byte])(nil)
 
 // An item in the key-value cache store.
-type item struct {
-	value   []byte
-	expires time.Time // Time when the key-value expires
+type item[V any] struct {
+	value   V
+	expires time.Time     // Time when the key-value expires
+	elem    *list.Element // Item's place in the LRU list.
+}
+
+// Cache statistics.
+type Stats struct {
+	Hits        uint64 // Gets that found an unexpired item.
+	Misses      uint64 // Gets that did not.
+	Evictions   uint64 // Items removed to stay within the limits.
+	Expirations uint64 // Expired items removed.
+	Entries     int    // Items in the cache.
+	Bytes       int    // Size of the items in the cache, if known.
+}
+
+// An in-memory key-value cache store.
+//
+// A Cache[string, []byte] is a byte-level store that is a Store;
+// other instances hold decoded values, so that they need not be
+// decoded again on every hit.
+//
+// The cache can be bounded by the number of items and by the size of
+// the items; when it is full, the least recently used items are
+// evicted.
+type Cache[K comparable, V any] struct {
+	sema       chan int // Semaphore for read/write access to cache.
+	store      map[K]item[V]
+	lru        *list.List     // Keys; most recently used first.
+	maxEntries int            // Zero if unbounded.
+	maxBytes   int            // Zero if unbounded.
+	size       func(K, V) int // Size of an item; nil if not known.
+	grace      time.Duration
+	stats      Stats
 }
 
-// A key-value cache store.
-type Cache struct {
-	sema  chan int // Semaphore for read/write access to cache.
-	store map[string]item
+// Returns a new empty byte-level cache store.
+func NewCache() *Cache[string, []byte] {
+	return NewBoundedCache(0, 0)
 }
 
-// Returns a new empty cache store.
-func NewCache() *Cache {
-	c := new(Cache)
+// Returns a new empty byte-level cache store that holds at most
+// `maxEntries` items whose keys and values add up to at most
+// `maxBytes` bytes. A limit of zero means no limit.
+func NewBoundedCache(maxEntries, maxBytes int) *Cache[string, []byte] {
+	return NewBounded(maxEntries, maxBytes, func(k string, v []byte) int {
+		return len(k) + len(v)
+	})
+}
+
+// Returns a new empty cache store for values of type V.
+func New[K comparable, V any]() *Cache[K, V] {
+	return NewBounded[K, V](0, 0, nil)
+}
+
+// Returns a new empty cache store for values of type V that holds at
+// most `maxEntries` items whose sizes, as given by `size`, add up to
+// at most `maxBytes`. A limit of zero means no limit; `size` may be
+// nil if `maxBytes` is zero.
+func NewBounded[K comparable, V any](maxEntries, maxBytes int, size func(K, V) int) *Cache[K, V] {
+	c := new(Cache[K, V])
 	c.sema = make(chan int, 1)
-	c.store = make(map[string]item)
+	c.store = make(map[K]item[V])
+	c.lru = list.New()
+	c.maxEntries = maxEntries
+	c.maxBytes = maxBytes
+	c.size = size
+	if size == nil {
+		c.maxBytes = 0
+	}
 	return c
 }
 
+// Keeps expired items in the cache store for `grace` after they
+// expire, so that they can be had with Cache.GetStale.
+func (c *Cache[K, V]) SetGrace(grace time.Duration) {
+	// Get sema token before accessing the cache.
+	c.sema <- 1
+	defer func() {
+		// Give up sema token.
+		<-c.sema
+	}()
+	c.grace = grace
+}
+
 // Set the (key,value) item to the cache store. This item will be
 // considered expired after time `expires`.
 //
-// Cache.Get will return an empty string once `expires` is past the
-// current time.
-func (c *Cache) Set(key string, value []byte, expires time.Time) {
+// Cache.Get will return the zero value of V once `expires` is past
+// the current time.
+//
+// Least recently used items are evicted if the cache goes over its
+// limits. An item that is larger than the cache's size limit is not
+// stored.
+func (c *Cache[K, V]) Set(key K, value V, expires time.Time) {
 	// Get sema token before accessing the cache.
 	c.sema <- 1
 	defer func() {
 		// Give up sema token.
 		<-c.sema
 	}()
-	c.store[key] = item{
+
+	c.remove(key)
+	if c.maxBytes > 0 && c.size(key, value) > c.maxBytes {
+		return
+	}
+	c.store[key] = item[V]{
 		value:   value,
 		expires: expires,
+		elem:    c.lru.PushFront(key),
+	}
+	c.stats.Bytes += c.sizeOf(key, value)
+
+	// Evict least recently used items.
+	for c.full() {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		c.remove(oldest.Value.(K))
+		c.stats.Evictions += 1
 	}
 }
 
 // Get an (key,value) item from the cache store by key.
 //
-// An empty []byte will be returned when if the key does not exist or
-// if the item corresponding to the key has expired.
-func (c *Cache) Get(key string) []byte {
+// The zero value of V, an empty []byte for byte-level stores, will be
+// returned when if the key does not exist or if the item
+// corresponding to the key has expired. Expired items are removed
+// from the cache once they are past the grace window.
+func (c *Cache[K, V]) Get(key K) V {
 	// Get sema token before accessing the cache.
 	c.sema <- 1
 	defer func() {
@@ -56,12 +166,175 @@ func (c *Cache) Get(key string) []byte {
 		<-c.sema
 	}()
 
-	if _, ok := c.store[key]; !ok {
-		return []byte{}
+	var zero V
+	i, ok := c.store[key]
+	if !ok {
+		c.stats.Misses += 1
+		return zero
 	}
 	// Check if the item expired.
-	if time.Until(c.store[key].expires).Seconds() < 0 {
-		return []byte{}
+	if time.Until(i.expires).Seconds() < 0 {
+		if c.dropped(i) {
+			c.remove(key)
+			c.stats.Expirations += 1
+		}
+		c.stats.Misses += 1
+		return zero
+	}
+
+	// Mark it as the most recently used.
+	if i.elem == nil {
+		i.elem = c.lru.PushFront(key)
+		c.store[key] = i
+	}
+	c.lru.MoveToFront(i.elem)
+	c.stats.Hits += 1
+	return i.value
+}
+
+// Get an (key,value) item from the cache store by key, along with the
+// time it expires; the item may have expired, but not be past the
+// grace window.
+//
+// The zero value of V and a zero time will be returned if the key
+// does not exist or if the item is past the grace window.
+func (c *Cache[K, V]) GetStale(key K) (V, time.Time) {
+	// Get sema token before accessing the cache.
+	c.sema <- 1
+	defer func() {
+		// Give up sema token.
+		<-c.sema
+	}()
+
+	var zero V
+	i, ok := c.store[key]
+	if !ok {
+		c.stats.Misses += 1
+		return zero, time.Time{}
+	}
+	if c.dropped(i) {
+		c.remove(key)
+		c.stats.Expirations += 1
+		c.stats.Misses += 1
+		return zero, time.Time{}
+	}
+
+	// Mark it as the most recently used.
+	if i.elem == nil {
+		i.elem = c.lru.PushFront(key)
+		c.store[key] = i
+	}
+	c.lru.MoveToFront(i.elem)
+	c.stats.Hits += 1
+	return i.value, i.expires
+}
+
+// Delete the (key,value) item from the cache store.
+func (c *Cache[K, V]) Delete(key K) {
+	// Get sema token before accessing the cache.
+	c.sema <- 1
+	defer func() {
+		// Give up sema token.
+		<-c.sema
+	}()
+	c.remove(key)
+}
+
+// Removes all items that are past the grace window from the cache
+// store. Returns the number of items removed.
+func (c *Cache[K, V]) Sweep() int {
+	// Get sema token before accessing the cache.
+	c.sema <- 1
+	defer func() {
+		// Give up sema token.
+		<-c.sema
+	}()
+
+	n := 0
+	for key, i := range c.store {
+		if c.dropped(i) {
+			c.remove(key)
+			n += 1
+		}
+	}
+	c.stats.Expirations += uint64(n)
+	return n
+}
+
+// Starts a janitor that sweeps expired items from the cache store
+// every `interval`. The janitor runs until the returned function is
+// called.
+func (c *Cache[K, V]) StartJanitor(interval time.Duration) (stop func()) {
+	done := make(chan int)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Sweep()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Returns the cache store's statistics.
+func (c *Cache[K, V]) Stats() Stats {
+	// Get sema token before accessing the cache.
+	c.sema <- 1
+	defer func() {
+		// Give up sema token.
+		<-c.sema
+	}()
+	stats := c.stats
+	stats.Entries = len(c.store)
+	return stats
+}
+
+// Returns true if item `i` is past the grace window and must be
+// dropped.
+//
+// The caller must hold the sema token.
+func (c *Cache[K, V]) dropped(i item[V]) bool {
+	return time.Since(i.expires) > c.grace
+}
+
+// Returns true if the cache store is over its limits.
+//
+// The caller must hold the sema token.
+func (c *Cache[K, V]) full() bool {
+	if c.maxEntries > 0 && len(c.store) > c.maxEntries {
+		return true
+	}
+	if c.maxBytes > 0 && c.stats.Bytes > c.maxBytes {
+		return true
+	}
+	return false
+}
+
+// Removes the (key,value) item from the cache store, if it exists.
+//
+// The caller must hold the sema token.
+func (c *Cache[K, V]) remove(key K) {
+	i, ok := c.store[key]
+	if !ok {
+		return
+	}
+	if i.elem != nil {
+		c.lru.Remove(i.elem)
+	}
+	delete(c.store, key)
+	c.stats.Bytes -= c.sizeOf(key, i.value)
+}
+
+// Returns the size of the (key,value) item; zero if the size is not
+// known.
+func (c *Cache[K, V]) sizeOf(key K, value V) int {
+	if c.size == nil {
+		return 0
 	}
-	return c.store[key].value
+	return c.size(key, value)
 }
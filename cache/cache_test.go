@@ -21,7 +21,7 @@ func TestNewCache(t *testing.T) {
 		return
 	}
 	// Try manually adding an item.
-	c.store["foo"] = item{
+	c.store["foo"] = item[[]byte]{
 		value:   []byte("bar"),
 		expires: time.Now().Add(time.Second * 10),
 	}
@@ -85,7 +85,7 @@ func TestConcurrentSets(t *testing.T) {
 	}
 
 	// Go routing for adding keys to cache.
-	addToCache := func(c *Cache, keys []string, donec chan int) {
+	addToCache := func(c *Cache[string, []byte], keys []string, donec chan int) {
 		for i := 0; i < len(keys); i++ {
 			c.Set(keys[i], []byte(fmt.Sprintf("val-%d", i)), exp)
 		}
@@ -114,3 +114,229 @@ func TestConcurrentSets(t *testing.T) {
 		t.Errorf("number of keys in store != %d: %v", maxKeys, c.store)
 	}
 }
+
+func TestCacheDelete(t *testing.T) {
+	c := NewCache()
+	c.Set("foo", []byte("bar"), time.Now().Add(time.Second*10))
+	c.Delete("foo")
+	if bytes.Compare(c.Get("foo"), []byte{}) != 0 {
+		t.Errorf("cache.Get(foo) is not empty after delete")
+		return
+	}
+	if _, ok := c.store["foo"]; ok {
+		t.Errorf("cache.store['foo'] exists after delete")
+		return
+	}
+}
+
+func TestCacheEvictsByEntries(t *testing.T) {
+	c := NewBoundedCache(3, 0)
+	exp := time.Now().Add(time.Second * 10)
+	c.Set("a", []byte("1"), exp)
+	c.Set("b", []byte("2"), exp)
+	c.Set("c", []byte("3"), exp)
+
+	// Use "a"; "b" is now the least recently used.
+	if bytes.Compare(c.Get("a"), []byte("1")) != 0 {
+		t.Errorf("cache.Get(a) is not 1")
+		return
+	}
+	c.Set("d", []byte("4"), exp)
+	if len(c.store) != 3 {
+		t.Errorf("number of keys in store != 3: %v", c.store)
+		return
+	}
+	if bytes.Compare(c.Get("b"), []byte{}) != 0 {
+		t.Errorf("cache.Get(b) is not evicted: %s", c.Get("b"))
+		return
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if len(c.Get(k)) == 0 {
+			t.Errorf("cache.Get(%s) is evicted", k)
+			return
+		}
+	}
+
+	// Overwriting a key does not evict.
+	c.Set("d", []byte("44"), exp)
+	if len(c.store) != 3 {
+		t.Errorf("number of keys in store != 3: %v", c.store)
+		return
+	}
+	if c.Stats().Evictions != 1 {
+		t.Errorf("evictions: %v", c.Stats())
+		return
+	}
+}
+
+func TestCacheEvictsByBytes(t *testing.T) {
+	c := NewBoundedCache(0, 20)
+	exp := time.Now().Add(time.Second * 10)
+	c.Set("a", []byte("123456789"), exp) // 10 bytes.
+	c.Set("b", []byte("123456789"), exp) // 20 bytes.
+	if c.Stats().Bytes != 20 {
+		t.Errorf("bytes: %v", c.Stats())
+		return
+	}
+
+	// Goes over by 5 bytes; "a" is evicted.
+	c.Set("c", []byte("1234"), exp)
+	if len(c.Get("a")) != 0 {
+		t.Errorf("cache.Get(a) is not evicted")
+		return
+	}
+	if len(c.Get("b")) == 0 || len(c.Get("c")) == 0 {
+		t.Errorf("cache.Get(b), cache.Get(c) evicted")
+		return
+	}
+	if c.Stats().Bytes != 15 {
+		t.Errorf("bytes: %v", c.Stats())
+		return
+	}
+
+	// Too big to be stored; replaces nothing else.
+	c.Set("d", []byte("12345678901234567890"), exp)
+	if len(c.Get("d")) != 0 {
+		t.Errorf("cache.Get(d) is stored")
+		return
+	}
+	if len(c.Get("b")) == 0 || len(c.Get("c")) == 0 {
+		t.Errorf("cache.Get(b), cache.Get(c) evicted")
+		return
+	}
+}
+
+func TestCacheSweep(t *testing.T) {
+	c := NewCache()
+	c.Set("foo", []byte("bar"), time.Now().Add(time.Second*10))
+	c.Set("sna", []byte("fu"), time.Now().Add(time.Second*-10))
+	c.Set("baz", []byte("qux"), time.Now().Add(time.Second*-10))
+	if n := c.Sweep(); n != 2 {
+		t.Errorf("sweep: removed %d != 2", n)
+		return
+	}
+	if len(c.store) != 1 {
+		t.Errorf("store: %v", c.store)
+		return
+	}
+	s := c.Stats()
+	if s.Expirations != 2 || s.Entries != 1 || s.Bytes != 6 {
+		t.Errorf("stats: %v", s)
+		return
+	}
+}
+
+func TestCacheJanitor(t *testing.T) {
+	c := NewCache()
+	c.Set("sna", []byte("fu"), time.Now().Add(time.Millisecond*10))
+	stop := c.StartJanitor(time.Millisecond * 20)
+	defer stop()
+
+	for i := 0; i < 50; i++ {
+		if c.Stats().Entries == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Errorf("janitor did not sweep: %v", c.Stats())
+}
+
+func TestCacheStats(t *testing.T) {
+	c := NewCache()
+	c.Set("foo", []byte("bar"), time.Now().Add(time.Second*10))
+	c.Set("sna", []byte("fu"), time.Now().Add(time.Second*-10))
+	c.Get("foo")
+	c.Get("foo")
+	c.Get("sna") // Expired.
+	c.Get("nope")
+	s := c.Stats()
+	if s.Hits != 2 || s.Misses != 2 || s.Expirations != 1 {
+		t.Errorf("stats: %v", s)
+		return
+	}
+	if s.Entries != 1 || s.Bytes != 6 {
+		t.Errorf("stats: %v", s)
+		return
+	}
+}
+
+func TestCacheGrace(t *testing.T) {
+	c := NewCache()
+	c.SetGrace(time.Second * 10)
+	c.Set("foo", []byte("bar"), time.Now().Add(time.Second*10))
+	c.Set("sna", []byte("fu"), time.Now().Add(time.Second*-5))
+	c.Set("baz", []byte("qux"), time.Now().Add(time.Second*-15))
+
+	// Fresh.
+	v, expires := c.GetStale("foo")
+	if string(v) != "bar" || time.Until(expires) < 0 {
+		t.Errorf("cache.GetStale(foo): %s %v", v, expires)
+		return
+	}
+
+	// Expired, but in the grace window.
+	if len(c.Get("sna")) != 0 {
+		t.Errorf("cache.Get(sna) is not empty")
+		return
+	}
+	v, expires = c.GetStale("sna")
+	if string(v) != "fu" || time.Until(expires) > 0 {
+		t.Errorf("cache.GetStale(sna): %s %v", v, expires)
+		return
+	}
+
+	// Past the grace window.
+	v, expires = c.GetStale("baz")
+	if len(v) != 0 || !expires.IsZero() {
+		t.Errorf("cache.GetStale(baz): %s %v", v, expires)
+		return
+	}
+	if _, ok := c.store["baz"]; ok {
+		t.Errorf("cache.GetStale(baz) is not removed")
+		return
+	}
+
+	// Sweep keeps items in the grace window.
+	c.Set("baz", []byte("qux"), time.Now().Add(time.Second*-15))
+	if n := c.Sweep(); n != 1 {
+		t.Errorf("sweep: removed %d != 1", n)
+		return
+	}
+	if _, ok := c.store["sna"]; !ok {
+		t.Errorf("sweep: sna is removed")
+		return
+	}
+}
+
+func TestTypedCache(t *testing.T) {
+	type forecast struct {
+		Temperature int
+	}
+	c := NewBounded(2, 0, func(k int, v *forecast) int { return 1 })
+	exp := time.Now().Add(time.Second * 10)
+	f := &forecast{72}
+	c.Set(1, f, exp)
+	c.Set(2, &forecast{75}, exp)
+	if c.Get(1) != f {
+		t.Errorf("cache.Get(1) is not the value that was set: %v", c.Get(1))
+		return
+	}
+	c.Set(3, &forecast{78}, exp)
+	if c.Get(2) != nil {
+		t.Errorf("cache.Get(2) is not evicted: %v", c.Get(2))
+		return
+	}
+	c.Set(4, &forecast{80}, time.Now().Add(time.Second*-10))
+	if c.Get(4) != nil {
+		t.Errorf("cache.Get(4) is not nil: %v", c.Get(4))
+		return
+	}
+
+	// Sizes are not known.
+	u := New[string, *forecast]()
+	u.Set("foo", f, exp)
+	if u.Get("foo") != f || u.Stats().Bytes != 0 {
+		t.Errorf("cache.Get(foo): %v: %v", u.Get("foo"), u.Stats())
+		return
+	}
+}
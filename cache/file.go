@@ -0,0 +1,223 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A key-value store that keeps each item in a file in a directory;
+// the items survive restarts.
+//
+// Each file has the item's expiration time, in Unix nanoseconds as a
+// big-endian int64, followed by the item's value.
+type FileStore struct {
+	dir      string
+	grace    time.Duration
+	maxBytes int64 // Zero if unbounded.
+}
+
+// FileStore is a Store.
+var _ Store = (*FileStore)(nil)
+
+// Returns a file store that keeps its items in directory `dir`. The
+// directory is created if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Keeps expired items in the file store for `grace` after they
+// expire, so that they can be had with FileStore.GetStale.
+//
+// Must be called before the store is used.
+func (s *FileStore) SetGrace(grace time.Duration) {
+	s.grace = grace
+}
+
+// Bounds the files in the store to `maxBytes` bytes; when a sweep
+// finds them over the bound, the items that expire first are removed.
+// A bound of zero means no bound.
+//
+// Must be called before the store is used.
+func (s *FileStore) SetMaxBytes(maxBytes int64) {
+	s.maxBytes = maxBytes
+}
+
+// Set the (key,value) item to the file store. This item will be
+// considered expired after time `expires`.
+//
+// Errors writing the item are ignored; the item is just not stored.
+func (s *FileStore) Set(key string, value []byte, expires time.Time) {
+	f, err := os.CreateTemp(s.dir, ".item-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name()) // No-op after the rename.
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint64(header, uint64(expires.UnixNano()))
+	_, err = f.Write(append(header, value...))
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return
+	}
+
+	// Rename is atomic; readers see either the old or the new item.
+	os.Rename(f.Name(), s.path(key))
+}
+
+// Get an (key,value) item from the file store by key.
+//
+// An empty []byte will be returned when if the key does not exist or
+// if the item corresponding to the key has expired. Expired items
+// are removed from the store once they are past the grace window.
+func (s *FileStore) Get(key string) []byte {
+	value, expires := s.GetStale(key)
+	if time.Until(expires).Seconds() < 0 {
+		return []byte{}
+	}
+	return value
+}
+
+// Get an (key,value) item from the file store by key, along with the
+// time it expires; the item may have expired, but not be past the
+// grace window.
+//
+// An empty []byte and a zero time will be returned if the key does
+// not exist or if the item is past the grace window.
+func (s *FileStore) GetStale(key string) ([]byte, time.Time) {
+	b, err := os.ReadFile(s.path(key))
+	if err != nil || len(b) < 8 {
+		return []byte{}, time.Time{}
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(b[:8])))
+	if time.Since(expires) > s.grace {
+		s.Delete(key)
+		return []byte{}, time.Time{}
+	}
+	return b[8:], expires
+}
+
+// Delete the (key,value) item from the file store.
+//
+// Errors removing the item are ignored.
+func (s *FileStore) Delete(key string) {
+	os.Remove(s.path(key))
+}
+
+// Returns the path of the file for `key`.
+func (s *FileStore) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(h[:]))
+}
+
+// Temporary files older than this are left over from a failed Set.
+const staleTemp = time.Hour
+
+// Removes the items that are past the grace window from the file
+// store, and then the items that expire first until the store is
+// within its bound. Returns the number of items removed.
+//
+// An item that is set while the store is swept may be removed; it is
+// then just fetched again.
+func (s *FileStore) Sweep() int {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0
+	}
+	type file struct {
+		path    string
+		size    int64
+		expires time.Time
+	}
+	files := []file{}
+	total := int64(0)
+	n := 0
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(s.dir, e.Name())
+		if strings.HasPrefix(e.Name(), ".item-") {
+			if time.Since(info.ModTime()) > staleTemp {
+				os.Remove(path)
+			}
+			continue
+		}
+		expires, ok := readExpires(path)
+		if !ok || time.Since(expires) > s.grace {
+			if os.Remove(path) == nil {
+				n += 1
+			}
+			continue
+		}
+		files = append(files, file{path, info.Size(), expires})
+		total += info.Size()
+	}
+	if s.maxBytes <= 0 || total <= s.maxBytes {
+		return n
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].expires.Before(files[j].expires)
+	})
+	for _, f := range files {
+		if total <= s.maxBytes {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			n += 1
+		}
+		total -= f.size
+	}
+	return n
+}
+
+// Starts a janitor that sweeps the file store every `interval`. The
+// janitor runs until the returned function is called.
+func (s *FileStore) StartJanitor(interval time.Duration) (stop func()) {
+	done := make(chan int)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Sweep()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Returns the expiration time in the header of item file `path`. The
+// second return value is false if the header cannot be read.
+func readExpires(path string) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(header))), true
+}
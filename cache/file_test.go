@@ -0,0 +1,188 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Errorf("new file store: %v", err)
+		return
+	}
+
+	// Test 1
+	exp := time.Now().Add(time.Second * 10)
+	s.Set("foo", []byte("bar"), exp)
+	if bytes.Compare(s.Get("foo"), []byte("bar")) != 0 {
+		t.Errorf("store.Get(foo) is not bar")
+		return
+	}
+
+	// Test 2
+	exp = time.Now().Add(time.Second * -10)
+	s.Set("sna", []byte("fu"), exp)
+	if bytes.Compare(s.Get("sna"), []byte{}) != 0 {
+		t.Errorf("store.Get(sna) is not empty: %s", s.Get("sna"))
+		return
+	}
+	if _, err := os.Stat(s.path("sna")); !os.IsNotExist(err) {
+		t.Errorf("expired item not removed: %v", err)
+		return
+	}
+
+	// Test 3
+	if bytes.Compare(s.Get("nope"), []byte{}) != 0 {
+		t.Errorf("store.Get(nope) is not empty: %s", s.Get("nope"))
+		return
+	}
+
+	// Test 4
+	s.Delete("foo")
+	if bytes.Compare(s.Get("foo"), []byte{}) != 0 {
+		t.Errorf("store.Get(foo) is not empty after delete")
+		return
+	}
+	s.Delete("foo") // Deleting a missing key is fine.
+}
+
+func TestFileStorePersists(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Errorf("new file store: %v", err)
+		return
+	}
+	exp := time.Now().Add(time.Second * 10)
+	s.Set("foo", []byte("bar"), exp)
+	s.Set("foo", []byte("baz"), exp) // Overwrite.
+
+	// Re-open the store.
+	s, err = NewFileStore(dir)
+	if err != nil {
+		t.Errorf("new file store: %v", err)
+		return
+	}
+	if bytes.Compare(s.Get("foo"), []byte("baz")) != 0 {
+		t.Errorf("store.Get(foo) is not baz: %s", s.Get("foo"))
+		return
+	}
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Errorf("read dir: %v", err)
+		return
+	}
+	if len(entries) != 1 {
+		t.Errorf("entries: %v", entries)
+		return
+	}
+}
+
+func TestFileStoreGrace(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Errorf("new file store: %v", err)
+		return
+	}
+	s.SetGrace(time.Second * 10)
+
+	exp := time.Now().Add(time.Second * -5)
+	s.Set("sna", []byte("fu"), exp)
+	if bytes.Compare(s.Get("sna"), []byte{}) != 0 {
+		t.Errorf("store.Get(sna) is not empty: %s", s.Get("sna"))
+		return
+	}
+	v, expires := s.GetStale("sna")
+	if bytes.Compare(v, []byte("fu")) != 0 || !expires.Equal(exp) {
+		t.Errorf("store.GetStale(sna): %s %v", v, expires)
+		return
+	}
+
+	s.Set("baz", []byte("qux"), time.Now().Add(time.Second*-15))
+	v, expires = s.GetStale("baz")
+	if len(v) != 0 || !expires.IsZero() {
+		t.Errorf("store.GetStale(baz): %s %v", v, expires)
+		return
+	}
+	if _, err := os.Stat(s.path("baz")); !os.IsNotExist(err) {
+		t.Errorf("item past grace window not removed: %v", err)
+		return
+	}
+}
+
+func TestFileStoreSweep(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Errorf("new file store: %v", err)
+		return
+	}
+	s.SetGrace(time.Second * 10)
+
+	now := time.Now()
+	s.Set("fresh", []byte("a"), now.Add(time.Minute))
+	s.Set("stale", []byte("b"), now.Add(time.Second*-5))
+	s.Set("gone", []byte("c"), now.Add(time.Second*-15))
+	os.WriteFile(filepath.Join(dir, "junk"), []byte("x"), 0600)
+	tmp := filepath.Join(dir, ".item-1")
+	os.WriteFile(tmp, []byte("partial"), 0600)
+	old := now.Add(-2 * staleTemp)
+	os.Chtimes(tmp, old, old)
+
+	if n := s.Sweep(); n != 2 {
+		t.Errorf("sweep: removed %d", n)
+		return
+	}
+	if len(s.Get("fresh")) == 0 {
+		t.Errorf("sweep: fresh item removed")
+		return
+	}
+	if v, _ := s.GetStale("stale"); len(v) == 0 {
+		t.Errorf("sweep: item in grace window removed")
+		return
+	}
+	for _, p := range []string{s.path("gone"), filepath.Join(dir, "junk"), tmp} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("sweep: %s not removed: %v", p, err)
+			return
+		}
+	}
+}
+
+func TestFileStoreMaxBytes(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Errorf("new file store: %v", err)
+		return
+	}
+	// Each item is an 8 byte header and a 92 byte value.
+	s.SetMaxBytes(250)
+	value := bytes.Repeat([]byte("x"), 92)
+	now := time.Now()
+	s.Set("c", value, now.Add(3*time.Minute))
+	s.Set("a", value, now.Add(1*time.Minute))
+	s.Set("b", value, now.Add(2*time.Minute))
+
+	if n := s.Sweep(); n != 1 {
+		t.Errorf("sweep: removed %d", n)
+		return
+	}
+	if len(s.Get("a")) != 0 || len(s.Get("b")) == 0 || len(s.Get("c")) == 0 {
+		t.Errorf("sweep: items that expire first not removed first")
+		return
+	}
+	if n := s.Sweep(); n != 0 {
+		t.Errorf("sweep: removed %d within bound", n)
+		return
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+// Inline SVG charts of the hourly forecast.
+package chart
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// An hour in the chart.
+type Hour struct {
+	Time                time.Time
+	Temperature         int
+	FeelsLike           *int // nil if not known.
+	PrecipitationChance int
+	Day                 bool
+}
+
+// Dimensions of the chart, in SVG user units.
+const (
+	width      = 440
+	height     = 180
+	padX       = 10  // Space on each side of the hours.
+	tempTop    = 30  // Top of the temperature lines.
+	tempBottom = 110 // Bottom of the temperature lines.
+	popTop     = 125 // Top of a 100% precipitation bar.
+	popBottom  = 160 // Bottom of the precipitation bars.
+)
+
+// Colors of the chart.
+const (
+	nightColor     = "rgb(242,242,242)"
+	feelsLikeColor = "rgb(130,130,130)"
+	popColor       = "rgb(120,160,210)"
+	labelColor     = "rgb(90,90,90)"
+)
+
+// Renders the temperature, feels-like temperature and precipitation
+// chance of `hours` as an inline SVG, with the night hours shaded.
+// Temperatures are in unit `unit`. Returns an empty string if there
+// are fewer than two hours.
+func Render(hours []Hour, unit string) template.HTML {
+	if len(hours) < 2 {
+		return ""
+	}
+	slot := float64(width-2*padX) / float64(len(hours))
+	x := func(i int) float64 { // Middle of hour `i`.
+		return padX + (float64(i)+0.5)*slot
+	}
+
+	// Temperature scale.
+	min, max := hours[0].Temperature, hours[0].Temperature
+	for _, h := range hours {
+		for _, t := range []*int{&h.Temperature, h.FeelsLike} {
+			if t != nil && *t < min {
+				min = *t
+			}
+			if t != nil && *t > max {
+				max = *t
+			}
+		}
+	}
+	if max == min {
+		min, max = min-1, max+1
+	}
+	y := func(t int) float64 {
+		return tempBottom - float64(t-min)/float64(max-min)*(tempBottom-tempTop)
+	}
+
+	b := new(strings.Builder)
+	fmt.Fprintf(b, `<svg class="chart" xmlns="http://www.w3.org/2000/svg" `+
+		`viewBox="0 0 %d %d" width="100%%" role="img" aria-label="%s">`,
+		width, height, html.EscapeString(fmt.Sprintf(
+			"temperature and chance of precipitation for the next %d hours",
+			len(hours))))
+
+	// Night shading.
+	for i := 0; i < len(hours); i++ {
+		if hours[i].Day {
+			continue
+		}
+		start := i
+		for i < len(hours) && !hours[i].Day {
+			i++
+		}
+		fmt.Fprintf(b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`,
+			padX+float64(start)*slot, tempTop-10, float64(i-start)*slot,
+			popBottom-tempTop+10, nightColor)
+	}
+
+	// Precipitation bars.
+	for i, h := range hours {
+		if h.PrecipitationChance <= 0 {
+			continue
+		}
+		bh := float64(h.PrecipitationChance) / 100 * (popBottom - popTop)
+		fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`,
+			x(i)-slot*0.4, popBottom-bh, slot*0.8, bh, popColor)
+	}
+
+	// Feels-like and temperature lines.
+	points := []string{}
+	for i, h := range hours {
+		if h.FeelsLike == nil {
+			continue
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(i), y(*h.FeelsLike)))
+	}
+	feelsLike := len(points) > 1
+	if feelsLike {
+		fmt.Fprintf(b, `<polyline points="%s" fill="none" stroke="%s" `+
+			`stroke-width="1.5" stroke-dasharray="4 3"/>`,
+			strings.Join(points, " "), feelsLikeColor)
+	}
+	points = points[:0]
+	hi, lo := 0, 0
+	for i, h := range hours {
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(i), y(h.Temperature)))
+		if h.Temperature > hours[hi].Temperature {
+			hi = i
+		}
+		if h.Temperature < hours[lo].Temperature {
+			lo = i
+		}
+	}
+	fmt.Fprintf(b, `<polyline points="%s" fill="none" stroke="rgb(0,0,0)" stroke-width="2"/>`,
+		strings.Join(points, " "))
+
+	// Highest and lowest temperatures.
+	label := func(i int, dy float64) {
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="%s">%d&deg;%s</text>`,
+			x(i), y(hours[i].Temperature)+dy, anchor(i, len(hours)),
+			hours[i].Temperature, html.EscapeString(unit))
+	}
+	label(hi, -6)
+	if hours[lo].Temperature != hours[hi].Temperature {
+		label(lo, 14)
+	}
+
+	// Hours, every six hours; the day at midnight.
+	for i, h := range hours {
+		if h.Time.Hour()%6 != 0 {
+			continue
+		}
+		s := fmt.Sprintf("%d", h.Time.Hour())
+		if h.Time.Hour() == 0 {
+			s = strings.ToLower(h.Time.Weekday().String()[:3])
+		}
+		fmt.Fprintf(b, `<text x="%.1f" y="%d" font-size="10" fill="%s" text-anchor="middle">%s</text>`,
+			x(i), height-6, labelColor, s)
+	}
+
+	// Legend.
+	fmt.Fprintf(b, `<g font-size="10" fill="%s">`+
+		`<line x1="%d" y1="8" x2="%d" y2="8" stroke="rgb(0,0,0)" stroke-width="2"/>`+
+		`<text x="%d" y="11">temperature</text>`,
+		labelColor, padX, padX+14, padX+18)
+	lx := padX + 90
+	if feelsLike {
+		fmt.Fprintf(b, `<line x1="%d" y1="8" x2="%d" y2="8" stroke="%s" `+
+			`stroke-width="1.5" stroke-dasharray="4 3"/>`+
+			`<text x="%d" y="11">feels like</text>`,
+			lx, lx+14, feelsLikeColor, lx+18)
+		lx += 80
+	}
+	fmt.Fprintf(b, `<rect x="%d" y="4" width="8" height="8" fill="%s"/>`+
+		`<text x="%d" y="11">precipitation</text></g>`,
+		lx, popColor, lx+12)
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// Returns the text anchor of a label at hour `i` of `n` hours, so that
+// labels at the edges stay in the chart.
+func anchor(i, n int) string {
+	switch {
+	case i < n/12:
+		return "start"
+	case i >= n-n/12:
+		return "end"
+	}
+	return "middle"
+}
@@ -0,0 +1,84 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package chart
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns `n` hours from midnight UTC on 2022-08-07, a Sunday. Hours
+// from 6:00 to 18:00 are day hours and it rains at 14:00.
+func testHours(n int, feelsLike bool) []Hour {
+	start := time.Date(2022, 8, 7, 0, 0, 0, 0, time.UTC)
+	hours := []Hour{}
+	for i := 0; i < n; i++ {
+		t := start.Add(time.Duration(i) * time.Hour)
+		h := Hour{
+			Time:        t,
+			Temperature: 60 + t.Hour(),
+			Day:         t.Hour() >= 6 && t.Hour() < 18,
+		}
+		if t.Hour() == 14 {
+			h.PrecipitationChance = 60
+		}
+		if feelsLike {
+			f := 58 + t.Hour()
+			h.FeelsLike = &f
+		}
+		hours = append(hours, h)
+	}
+	return hours
+}
+
+func TestRender(t *testing.T) {
+	if s := Render(testHours(1, true), "F"); len(s) > 0 {
+		t.Errorf("render: one hour: %v", s)
+		return
+	}
+
+	s := string(Render(testHours(48, true), "F"))
+	if !strings.HasPrefix(s, `<svg class="chart"`) || !strings.HasSuffix(s, "</svg>") {
+		t.Errorf("render: %v", s)
+		return
+	}
+	if n := strings.Count(s, "<polyline"); n != 2 {
+		t.Errorf("render: lines: %v", n)
+		return
+	}
+	// Nights are 0:00 to 6:00, 18:00 to 6:00 and 18:00 to 0:00.
+	if n := strings.Count(s, `fill="`+nightColor+`"`); n != 3 {
+		t.Errorf("render: nights: %v", n)
+		return
+	}
+	if n := strings.Count(s, `fill="`+popColor+`"`); n != 3 {
+		t.Errorf("render: precipitation: %v", n)
+		return
+	}
+	for _, l := range []string{">83&deg;F<", ">60&deg;F<", ">sun<", ">mon<",
+		">6<", ">18<", ">feels like<", "next 48 hours"} {
+		if !strings.Contains(s, l) {
+			t.Errorf("render: no %s: %v", l, s)
+			return
+		}
+	}
+
+	// Without feels-like temperatures.
+	s = string(Render(testHours(24, false), "C"))
+	if strings.Count(s, "<polyline") != 1 || strings.Contains(s, "feels like") ||
+		!strings.Contains(s, ">83&deg;C<") {
+		t.Errorf("render: no feels like: %v", s)
+		return
+	}
+
+	// Constant temperature.
+	hours := testHours(2, false)
+	hours[1].Temperature = hours[0].Temperature
+	s = string(Render(hours, "F"))
+	if strings.Contains(s, "NaN") || strings.Count(s, "&deg;") != 1 {
+		t.Errorf("render: constant: %v", s)
+		return
+	}
+}
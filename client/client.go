@@ -5,17 +5,22 @@
 package client
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"ricketyspace.net/peach/version"
 )
 
-// HTTP client.
-var client = http.Client{}
+// HTTP client. The timeout is a backstop for requests whose context
+// has no deadline.
+var client = http.Client{
+	Timeout: 30 * time.Second,
+}
 
-// Make a HTTP GET request.
-func Get(url string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// Make a HTTP GET request. The request is canceled when `ctx` is done.
+func Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -4,11 +4,14 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"ricketyspace.net/peach/version"
 )
@@ -41,7 +44,7 @@ func TestGet(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	res, err := Get(ts.URL)
+	res, err := Get(context.Background(), ts.URL)
 	if err != nil {
 		t.Errorf("get failed: %v", err)
 		return
@@ -52,3 +55,25 @@ func TestGet(t *testing.T) {
 		return
 	}
 }
+
+func TestGetContext(t *testing.T) {
+	done := make(chan int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done // Hang until the test is over.
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+	_, err := Get(ctx, ts.URL)
+	if err == nil {
+		t.Errorf("get did not fail")
+		return
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("get: %v", err)
+		return
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package discussion
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"ricketyspace.net/peach/nws"
+	"ricketyspace.net/peach/version"
+)
+
+type Discussion struct {
+	Title     string
+	Version   string
+	Canonical string // Canonical URL path of the page.
+	Office    string // Forecast office name; for instance, Cleveland OH.
+	IssuedAt  string
+	Sections  []Section
+}
+
+// A section of an Area Forecast Discussion; for instance, SYNOPSIS or
+// NEAR TERM /THROUGH TONIGHT/.
+type Section struct {
+	Name       string   // SYNOPSIS, NEAR TERM, ...
+	Period     string   // THROUGH TONIGHT, ...; may be empty.
+	Paragraphs []string // Line breaks within paragraphs are kept.
+}
+
+// Forecast office regex.
+var wfoRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Section header regex for matching headers like
+// .NEAR TERM /THROUGH TONIGHT/...
+var sectionRegex = regexp.MustCompile(`^\.([^.]+?)\.\.\.(.*)$`)
+
+// Issuance time regex for matching times like
+// 641 AM EDT Sun Aug 7 2022
+var issuedRegex = regexp.MustCompile(`^[0-9]{3,4} [AP]M [A-Z]{3,4} [A-Z][a-z]{2} [A-Z][a-z]{2} [0-9]{1,2} [0-9]{4}$`)
+
+// Makes the latest Area Forecast Discussion from forecast office `wfo`.
+func NewDiscussion(ctx context.Context, wfo string) (*Discussion, error, int) {
+	wfo = strings.ToUpper(wfo)
+	if !wfoRegex.MatchString(wfo) {
+		return nil, fmt.Errorf("forecast office invalid: %s", wfo), 400
+	}
+
+	// Get the latest discussion.
+	products, nwsErr := nws.GetProducts(ctx, "AFD", wfo)
+	if nwsErr != nil {
+		return nil, nwsErr, nwsErr.Status
+	}
+	if len(products.Graph) == 0 {
+		return nil, fmt.Errorf("no discussion from %s", wfo), 404
+	}
+	product, nwsErr := nws.GetProduct(ctx, products.Graph[0].Id)
+	if nwsErr != nil {
+		return nil, nwsErr, nwsErr.Status
+	}
+
+	d := new(Discussion)
+	d.Version = version.Version
+	d.Canonical = "/discussion/" + wfo
+	d.Office = wfo
+	d.IssuedAt = product.IssuanceTime
+	if t, err := time.Parse(time.RFC3339, product.IssuanceTime); err == nil {
+		d.IssuedAt = t.Format("3:04 PM MST Mon Jan 2 2006")
+	}
+	for _, l := range strings.Split(product.ProductText, "\n") {
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, "National Weather Service ") {
+			d.Office = strings.TrimPrefix(l, "National Weather Service ")
+		}
+		if issuedRegex.MatchString(l) {
+			d.IssuedAt = l
+			break
+		}
+	}
+	d.Title = "discussion " + strings.ToLower(d.Office)
+	d.Sections = Sections(product.ProductText)
+	return d, nil, 200
+}
+
+// Splits Area Forecast Discussion `text` into its sections.
+//
+// A section starts with a header like .SYNOPSIS... and ends with a
+// line that has just && on it or with the start of the next
+// section. Everything before the first section and after the $$ line
+// is dropped.
+func Sections(text string) []Section {
+	sections := []Section{}
+	var section *Section
+	paragraph := []string{}
+
+	// Adds the current paragraph to the current section.
+	endParagraph := func() {
+		if section != nil && len(paragraph) > 0 {
+			section.Paragraphs = append(section.Paragraphs,
+				strings.Join(paragraph, "\n"))
+		}
+		paragraph = []string{}
+	}
+	// Adds the current section to the list of sections.
+	endSection := func() {
+		endParagraph()
+		if section != nil {
+			sections = append(sections, *section)
+		}
+		section = nil
+	}
+
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.TrimRight(l, " \r")
+		if l == "$$" {
+			break
+		}
+		if l == "&&" {
+			endSection()
+			continue
+		}
+		if m := sectionRegex.FindStringSubmatch(l); m != nil {
+			endSection()
+			section = &Section{Paragraphs: []string{}}
+			section.Name, section.Period, _ = strings.Cut(m[1], " /")
+			section.Name = strings.TrimSpace(section.Name)
+			section.Period = strings.TrimSuffix(section.Period, "/")
+			if rest := strings.TrimSpace(m[2]); len(rest) > 0 {
+				paragraph = append(paragraph, rest)
+			}
+			continue
+		}
+		if len(strings.TrimSpace(l)) == 0 {
+			endParagraph()
+			continue
+		}
+		paragraph = append(paragraph, l)
+	}
+	endSection()
+	return sections
+}
@@ -0,0 +1,102 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package discussion
+
+import "testing"
+
+const afd = `
+000
+FXUS61 KCLE 071041
+AFDCLE
+
+Area Forecast Discussion
+National Weather Service Cleveland OH
+641 AM EDT Sun Aug 7 2022
+
+.SYNOPSIS...
+A warm front will lift north across the area today. A cold front
+will cross the region Monday night.
+
+&&
+
+.NEAR TERM /THROUGH TONIGHT/...
+Showers and thunderstorms will develop this afternoon.
+
+Highs in the upper 80s.
+&&
+
+.AVIATION /12Z SUNDAY THROUGH THURSDAY/...VFR expected through
+the period.
+
+OUTLOOK...Non-VFR possible Monday.
+
+&&
+
+.CLE WATCHES/WARNINGS/ADVISORIES...
+OH...None.
+PA...None.
+&&
+
+$$
+
+SYNOPSIS...Sefcovic
+NEAR TERM...Sefcovic
+`
+
+func TestSections(t *testing.T) {
+	sections := Sections(afd)
+	if len(sections) != 4 {
+		t.Errorf("sections: %v", sections)
+		return
+	}
+
+	s := sections[0]
+	if s.Name != "SYNOPSIS" || s.Period != "" {
+		t.Errorf("synopsis: %q %q", s.Name, s.Period)
+		return
+	}
+	if len(s.Paragraphs) != 1 || s.Paragraphs[0] != "A warm front will lift north across the area today. A cold front\nwill cross the region Monday night." {
+		t.Errorf("synopsis: paragraphs: %q", s.Paragraphs)
+		return
+	}
+
+	s = sections[1]
+	if s.Name != "NEAR TERM" || s.Period != "THROUGH TONIGHT" {
+		t.Errorf("near term: %q %q", s.Name, s.Period)
+		return
+	}
+	if len(s.Paragraphs) != 2 || s.Paragraphs[1] != "Highs in the upper 80s." {
+		t.Errorf("near term: paragraphs: %q", s.Paragraphs)
+		return
+	}
+
+	s = sections[2]
+	if s.Name != "AVIATION" || s.Period != "12Z SUNDAY THROUGH THURSDAY" {
+		t.Errorf("aviation: %q %q", s.Name, s.Period)
+		return
+	}
+	if len(s.Paragraphs) != 2 || s.Paragraphs[0] != "VFR expected through\nthe period." ||
+		s.Paragraphs[1] != "OUTLOOK...Non-VFR possible Monday." {
+		t.Errorf("aviation: paragraphs: %q", s.Paragraphs)
+		return
+	}
+
+	s = sections[3]
+	if s.Name != "CLE WATCHES/WARNINGS/ADVISORIES" || s.Period != "" {
+		t.Errorf("watches: %q %q", s.Name, s.Period)
+		return
+	}
+	if len(s.Paragraphs) != 1 || s.Paragraphs[0] != "OH...None.\nPA...None." {
+		t.Errorf("watches: paragraphs: %q", s.Paragraphs)
+		return
+	}
+}
+
+func TestSectionsEmpty(t *testing.T) {
+	sections := Sections("000\nFXUS61 KCLE 071041\nAFDCLE\n")
+	if len(sections) != 0 {
+		t.Errorf("sections: %v", sections)
+		return
+	}
+}
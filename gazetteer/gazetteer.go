@@ -0,0 +1,287 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+// Offline geocoder backed by the US Census Gazetteer files of places
+// and ZIP Code Tabulation Areas (ZCTAs).
+//
+// The files are embedded from data/; bin/gazetteer builds them.
+package gazetteer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"math"
+	"strconv"
+	"strings"
+
+	"ricketyspace.net/peach/geo"
+)
+
+// Gazetteer files; data/README and the files that bin/gazetteer
+// builds next to it.
+//
+//go:embed data
+var dataFS embed.FS
+
+// Maximum number of places that Geocode returns.
+const maxMatches = 10
+
+// Reverse finds no place farther than this, in kilometers.
+const maxReverseDistance = 50.0
+
+// Mean radius of the earth, in kilometers.
+const earthRadius = 6371.0
+
+// A place in the gazetteer.
+type place struct {
+	name  string // Tiffin
+	key   string // Lower case name; tiffin
+	state string // OH
+	lat   float32
+	lng   float32
+}
+
+// An offline geo.Geocoder.
+type Gazetteer struct {
+	places []place
+	zctas  map[string]geo.Coordinates
+	zip    geo.Geocoder // Looks up ZIP codes that are not ZCTAs; may be nil.
+}
+
+var _ geo.Geocoder = (*Gazetteer)(nil)
+
+// Returns a gazetteer of the embedded places and ZCTAs.
+func New() (*Gazetteer, error) {
+	fsys, err := fs.Sub(dataFS, "data")
+	if err != nil {
+		return nil, fmt.Errorf("gazetteer: %v", err)
+	}
+	return newGazetteer(fsys)
+}
+
+// Returns a gazetteer of the places and ZCTAs in places.tsv.gz and
+// zctas.tsv.gz in `fsys`.
+func newGazetteer(fsys fs.FS) (*Gazetteer, error) {
+	g := new(Gazetteer)
+	err := readTSV(fsys, "places.tsv.gz", 4, func(f []string) error {
+		lat, lng, err := parseLatLng(f[2], f[3])
+		if err != nil {
+			return err
+		}
+		g.places = append(g.places, place{
+			name:  f[0],
+			key:   strings.ToLower(f[0]),
+			state: f[1],
+			lat:   lat,
+			lng:   lng,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	g.zctas = make(map[string]geo.Coordinates)
+	err = readTSV(fsys, "zctas.tsv.gz", 3, func(f []string) error {
+		lat, lng, err := parseLatLng(f[1], f[2])
+		if err != nil {
+			return err
+		}
+		g.zctas[f[0]] = geo.Coordinates{Lat: lat, Lng: lng, Zip: f[0]}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Looks up ZIP codes that are not in the ZCTAs with `z`; for
+// instance, ZIP codes of PO boxes.
+func (g *Gazetteer) SetZipFallback(z geo.Geocoder) {
+	g.zip = z
+}
+
+// Returns the places that match `location`.
+//
+// `location` is a place name, optionally followed by a state; for
+// instance, Tiffin, Tiffin OH or Tiffin, Ohio. Places whose names
+// match exactly come first, followed by places whose names start with
+// the given name. ZIP codes are looked up with Zip.
+func (g *Gazetteer) Geocode(ctx context.Context, location string) ([]geo.Coordinates, error) {
+	mCoords := []geo.Coordinates{} // Matching coordinates
+	location = strings.TrimSpace(location)
+	if len(location) < 2 {
+		return mCoords, fmt.Errorf("geocode: location invalid")
+	}
+	if zip, ok := geo.ParseZip(location); ok {
+		return g.Zip(ctx, zip)
+	}
+
+	name, state := splitLocation(location)
+	prefixed := []geo.Coordinates{}
+	for _, p := range g.places {
+		if len(state) > 0 && p.state != state {
+			continue
+		}
+		c := geo.Coordinates{
+			Lat:         p.lat,
+			Lng:         p.lng,
+			Name:        fmt.Sprintf("%s, %s", p.name, p.state),
+			State:       p.state,
+			CountryCode: "US",
+		}
+		if p.key == name {
+			mCoords = append(mCoords, c)
+		} else if strings.HasPrefix(p.key, name) {
+			prefixed = append(prefixed, c)
+		}
+	}
+	mCoords = append(mCoords, prefixed...)
+	if len(mCoords) > maxMatches {
+		mCoords = mCoords[:maxMatches]
+	}
+	return mCoords, nil
+}
+
+// Returns the place nearest to coordinates `lat`,`lng`.
+func (g *Gazetteer) Reverse(ctx context.Context, lat, lng float32) (*geo.Coordinates, error) {
+	p := g.nearest(lat, lng)
+	if p == nil {
+		return nil, fmt.Errorf("reverse: no place near %.4f,%.4f", lat, lng)
+	}
+	return &geo.Coordinates{
+		Lat:         lat,
+		Lng:         lng,
+		Name:        fmt.Sprintf("%s, %s", p.name, p.state),
+		State:       p.state,
+		CountryCode: "US",
+	}, nil
+}
+
+// Returns the centroid of ZCTA `zip`. It is named after the place
+// nearest to it; the Census files do not name the primary city of a
+// ZCTA. ZIP codes that are not ZCTAs are looked up with the fallback
+// set by SetZipFallback, if any.
+func (g *Gazetteer) Zip(ctx context.Context, zip string) ([]geo.Coordinates, error) {
+	c, ok := g.zctas[zip]
+	if !ok && g.zip != nil {
+		return g.zip.Zip(ctx, zip)
+	}
+	if !ok {
+		return []geo.Coordinates{}, nil
+	}
+	c.Name = zip
+	c.CountryCode = "US"
+	if p := g.nearest(c.Lat, c.Lng); p != nil {
+		c.Name = fmt.Sprintf("%s, %s", p.name, p.state)
+		c.State = p.state
+	}
+	return []geo.Coordinates{c}, nil
+}
+
+// Returns the place nearest to coordinates `lat`,`lng`; nil if there
+// is no place within maxReverseDistance.
+func (g *Gazetteer) nearest(lat, lng float32) *place {
+	var nearest *place
+	min := math.Inf(1)
+	for i := range g.places {
+		d := distance(lat, lng, g.places[i].lat, g.places[i].lng)
+		if d < min {
+			nearest, min = &g.places[i], d
+		}
+	}
+	if min > maxReverseDistance {
+		return nil
+	}
+	return nearest
+}
+
+// Splits `location` into a lower case place name and a state code.
+// The state code is empty if `location` does not end with a state.
+func splitLocation(location string) (string, string) {
+	location = strings.ToLower(location)
+	if name, state, ok := strings.Cut(location, ","); ok {
+		name = strings.TrimSpace(name)
+		if code := geo.StateCode(state); len(code) > 0 {
+			return name, code
+		}
+		return name, ""
+	}
+
+	// State may be the last one or two words; for instance,
+	// tiffin oh or albany new york.
+	words := strings.Fields(location)
+	for n := 2; n > 0; n-- {
+		if len(words) <= n {
+			continue
+		}
+		code := geo.StateCode(strings.Join(words[len(words)-n:], " "))
+		if len(code) > 0 {
+			return strings.Join(words[:len(words)-n], " "), code
+		}
+	}
+	return strings.Join(words, " "), ""
+}
+
+// Returns the great-circle distance, in kilometers, between two
+// coordinates.
+func distance(lat1, lng1, lat2, lng2 float32) float64 {
+	rad := func(d float32) float64 { return float64(d) * math.Pi / 180 }
+	dLat := rad(lat2 - lat1)
+	dLng := rad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*
+			math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// Parses latitude `lat` and longitude `lng`.
+func parseLatLng(lat, lng string) (float32, float32, error) {
+	la, err := strconv.ParseFloat(lat, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	ln, err := strconv.ParseFloat(lng, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return float32(la), float32(ln), nil
+}
+
+// Calls `each` with the fields of every line in gzipped tab-separated
+// file `name` in `fsys`. Every line must have `fields` fields.
+func readTSV(fsys fs.FS, name string, fields int, each func([]string) error) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fmt.Errorf("gazetteer: %v; bin/gazetteer builds it", err)
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("gazetteer: %s: %v", name, err)
+	}
+	defer z.Close()
+
+	s := bufio.NewScanner(z)
+	n := 0
+	for s.Scan() {
+		n += 1
+		f := strings.Split(s.Text(), "\t")
+		if len(f) != fields {
+			return fmt.Errorf("gazetteer: %s:%d: %d fields, not %d",
+				name, n, len(f), fields)
+		}
+		if err := each(f); err != nil {
+			return fmt.Errorf("gazetteer: %s:%d: %v", name, n, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("gazetteer: %s: %v", name, err)
+	}
+	return nil
+}
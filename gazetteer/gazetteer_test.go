@@ -0,0 +1,211 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package gazetteer
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"testing"
+
+	"ricketyspace.net/peach/geo"
+)
+
+func TestGeocode(t *testing.T) {
+	g, err := newGazetteer(os.DirFS("testdata"))
+	if err != nil {
+		t.Errorf("new: %v", err)
+		return
+	}
+
+	for _, l := range []string{"Tiffin, OH", "tiffin oh", "Tiffin, Ohio",
+		"  TIFFIN  "} {
+		mCoords, err := g.Geocode(context.Background(), l)
+		if err != nil {
+			t.Errorf("geocode: %s: %v", l, err)
+			return
+		}
+		if len(mCoords) != 1 {
+			t.Errorf("geocode: %s: %v", l, mCoords)
+			return
+		}
+		if mCoords[0].Name != "Tiffin, OH" || !mCoords[0].InState("OH") {
+			t.Errorf("geocode: %s: name: %v", l, mCoords[0])
+			return
+		}
+		if mCoords[0].Lat != 41.1145 || mCoords[0].Lng != -83.178 {
+			t.Errorf("geocode: %s: %v", l, mCoords[0])
+			return
+		}
+	}
+
+	// Exact matches come before prefix matches.
+	mCoords, err := g.Geocode(context.Background(), "columbus")
+	if err != nil {
+		t.Errorf("geocode: columbus: %v", err)
+		return
+	}
+	if len(mCoords) != 3 {
+		t.Errorf("geocode: columbus: %v", mCoords)
+		return
+	}
+	mCoords, err = g.Geocode(context.Background(), "port")
+	if err != nil {
+		t.Errorf("geocode: port: %v", err)
+		return
+	}
+	if len(mCoords) != 2 || mCoords[0].Name != "Portland, ME" {
+		t.Errorf("geocode: port: %v", mCoords)
+		return
+	}
+
+	// States with two words.
+	mCoords, err = g.Geocode(context.Background(), "new york new york")
+	if err != nil || len(mCoords) != 1 || mCoords[0].Name != "New York, NY" {
+		t.Errorf("geocode: new york: %v %v", mCoords, err)
+		return
+	}
+
+	// ZIP code.
+	mCoords, err = g.Geocode(context.Background(), "44883-1234")
+	if err != nil || len(mCoords) != 1 || mCoords[0].Zip != "44883" {
+		t.Errorf("geocode: 44883-1234: %v %v", mCoords, err)
+		return
+	}
+
+	// Nothing.
+	mCoords, err = g.Geocode(context.Background(), "Atlantis, OH")
+	if err != nil || len(mCoords) != 0 {
+		t.Errorf("geocode: atlantis: %v %v", mCoords, err)
+		return
+	}
+	_, err = g.Geocode(context.Background(), "x")
+	if err == nil {
+		t.Errorf("geocode: x: no error")
+		return
+	}
+}
+
+func TestReverse(t *testing.T) {
+	g, err := newGazetteer(os.DirFS("testdata"))
+	if err != nil {
+		t.Errorf("new: %v", err)
+		return
+	}
+	c, err := g.Reverse(context.Background(), 41.115, -83.177)
+	if err != nil {
+		t.Errorf("reverse: %v", err)
+		return
+	}
+	if c.Name != "Tiffin, OH" || c.Lat != 41.115 || c.Lng != -83.177 {
+		t.Errorf("reverse: %v", c)
+		return
+	}
+
+	// Middle of the Atlantic.
+	c, err = g.Reverse(context.Background(), 35, -45)
+	if err == nil {
+		t.Errorf("reverse: atlantic: %v", c)
+		return
+	}
+}
+
+func TestZip(t *testing.T) {
+	g, err := newGazetteer(os.DirFS("testdata"))
+	if err != nil {
+		t.Errorf("new: %v", err)
+		return
+	}
+	mCoords, err := g.Zip(context.Background(), "44883")
+	if err != nil {
+		t.Errorf("zip: %v", err)
+		return
+	}
+	if len(mCoords) != 1 {
+		t.Errorf("zip: %v", mCoords)
+		return
+	}
+	c := mCoords[0]
+	if c.Zip != "44883" || c.Name != "Tiffin, OH" || !c.InState("OH") {
+		t.Errorf("zip: %v", c)
+		return
+	}
+	if c.Lat != 41.118 || c.Lng != -83.17 {
+		t.Errorf("zip: %v", c)
+		return
+	}
+
+	// Not a ZCTA.
+	mCoords, err = g.Zip(context.Background(), "00000")
+	if err != nil || len(mCoords) != 0 {
+		t.Errorf("zip: 00000: %v %v", mCoords, err)
+		return
+	}
+
+	// Not a ZCTA, with a fallback.
+	z := &zipGeocoder{}
+	g.SetZipFallback(z)
+	mCoords, err = g.Zip(context.Background(), "00000")
+	if err != nil || len(mCoords) != 1 || mCoords[0].Name != "Fallback, OH" {
+		t.Errorf("zip: fallback: %v %v", mCoords, err)
+		return
+	}
+	mCoords, err = g.Zip(context.Background(), "44883")
+	if err != nil || len(mCoords) != 1 || mCoords[0].Name != "Tiffin, OH" {
+		t.Errorf("zip: fallback: 44883: %v %v", mCoords, err)
+		return
+	}
+	if len(z.zips) != 1 || z.zips[0] != "00000" {
+		t.Errorf("zip: fallback: lookups: %v", z.zips)
+		return
+	}
+}
+
+func TestData(t *testing.T) {
+	if _, err := fs.Stat(dataFS, "data/places.tsv.gz"); err != nil {
+		t.Skipf("data: %v; bin/gazetteer builds it", err)
+	}
+	g, err := New()
+	if err != nil {
+		t.Errorf("new: %v", err)
+		return
+	}
+	if len(g.places) < 25000 {
+		t.Errorf("data: %d places; run bin/gazetteer", len(g.places))
+		return
+	}
+	if len(g.zctas) < 30000 {
+		t.Errorf("data: %d zctas; run bin/gazetteer", len(g.zctas))
+		return
+	}
+	mCoords, err := g.Geocode(context.Background(), "Tiffin, OH")
+	if err != nil || len(mCoords) != 1 {
+		t.Errorf("data: tiffin: %v %v", mCoords, err)
+		return
+	}
+	mCoords, err = g.Zip(context.Background(), "44883")
+	if err != nil || len(mCoords) != 1 {
+		t.Errorf("data: 44883: %v %v", mCoords, err)
+		return
+	}
+}
+
+// A geo.Geocoder that finds every ZIP code.
+type zipGeocoder struct {
+	zips []string // ZIP codes looked up.
+}
+
+func (z *zipGeocoder) Geocode(ctx context.Context, location string) ([]geo.Coordinates, error) {
+	return []geo.Coordinates{}, nil
+}
+
+func (z *zipGeocoder) Reverse(ctx context.Context, lat, lng float32) (*geo.Coordinates, error) {
+	return nil, fmt.Errorf("reverse: not supported")
+}
+
+func (z *zipGeocoder) Zip(ctx context.Context, zip string) ([]geo.Coordinates, error) {
+	z.zips = append(z.zips, zip)
+	return []geo.Coordinates{{Lat: 41, Lng: -83, Name: "Fallback, OH", Zip: zip}}, nil
+}
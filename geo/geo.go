@@ -0,0 +1,94 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+// Geocoding interface that the geocoding backends implement.
+package geo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ZIP and ZIP+4 code regex.
+var zipRegex = regexp.MustCompile(`^([0-9]{5})(?:[- ]?[0-9]{4})?$`)
+
+// Lat,Lng regex.
+var latLngRegex = regexp.MustCompile(`^(-?[0-9]{1,3}(?:\.[0-9]+)?),(-?[0-9]{1,3}(?:\.[0-9]+)?)$`)
+
+// Matches a coordinate that is rounded to negative zero.
+var negZeroRegex = regexp.MustCompile(`(^|,)-0\.0000\b`)
+
+// Returned by ParseLatLng if the string is not a pair of coordinates.
+var ErrNotLatLng = errors.New("not a lat,lng pair")
+
+// Coordinates of a named place. The json field names are part of the
+// /api/search interface; do not rename them.
+type Coordinates struct {
+	Lat  float32 `json:"lat"`
+	Lng  float32 `json:"lng"`
+	Name string  `json:"name"`
+	Zip  string  `json:"zip,omitempty"` // ZIP code; set if the place was found by its ZIP code.
+
+	State       string `json:"-"` // State code; for instance, OH. Set for places in the US.
+	CountryCode string `json:"-"` // ISO 3166-1 country code; for instance, US.
+}
+
+// Finds places by their name and names places by their coordinates.
+type Geocoder interface {
+	// Returns a list of matching Coordinates for a given location;
+	// for instance, Tiffin, OH.
+	Geocode(ctx context.Context, location string) ([]Coordinates, error)
+
+	// Returns the place at coordinates `lat`,`lng`.
+	Reverse(ctx context.Context, lat, lng float32) (*Coordinates, error)
+
+	// Returns the centroid of five digit ZIP code `zip`, named after
+	// the ZIP code's primary city; an empty list if the ZIP code is
+	// not known.
+	Zip(ctx context.Context, zip string) ([]Coordinates, error)
+}
+
+// Returns the five digit ZIP code in `s` if `s` is a ZIP or a ZIP+4
+// code; for instance, 44883 or 44883-1234.
+func ParseZip(s string) (string, bool) {
+	m := zipRegex.FindStringSubmatch(s)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// Returns coordinates `lat`,`lng` on the four decimal grid that the
+// NWS API points are cached on; for instance, 41.1150,-83.1770.
+func FormatLatLng(lat, lng float32) string {
+	ll := fmt.Sprintf("%.4f,%.4f", lat, lng)
+	return negZeroRegex.ReplaceAllString(ll, "${1}0.0000")
+}
+
+// Returns the latitude and the longitude in `s`; for instance, 41.115
+// and -83.177 for 41.115,-83.177. Returns ErrNotLatLng if `s` is not
+// of that form and an error if the coordinates are out of range.
+func ParseLatLng(s string) (float32, float32, error) {
+	m := latLngRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, ErrNotLatLng
+	}
+	lat, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	lng, err := strconv.ParseFloat(m[2], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("latitude %s is not between -90 and 90", m[1])
+	}
+	if lng < -180 || lng > 180 {
+		return 0, 0, fmt.Errorf("longitude %s is not between -180 and 180", m[2])
+	}
+	return float32(lat), float32(lng), nil
+}
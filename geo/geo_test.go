@@ -0,0 +1,82 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package geo
+
+import "testing"
+
+func TestParseZip(t *testing.T) {
+	for s, zip := range map[string]string{
+		"44883":      "44883",
+		"44883-1234": "44883",
+		"44883 1234": "44883",
+		"448831234":  "44883",
+		"02108":      "02108",
+	} {
+		z, ok := ParseZip(s)
+		if !ok || z != zip {
+			t.Errorf("parse zip: %s: %v %v", s, z, ok)
+			return
+		}
+	}
+	for _, s := range []string{"4488", "448831", "44883-12", "tiffin",
+		"44883-1234 ", "Tiffin 44883", ""} {
+		z, ok := ParseZip(s)
+		if ok {
+			t.Errorf("parse zip: %q: %v", s, z)
+			return
+		}
+	}
+}
+
+func TestParseLatLng(t *testing.T) {
+	for s, c := range map[string][2]float32{
+		"41.115,-83.177": {41.115, -83.177},
+		"5,-80":          {5, -80},
+		"-5.5,180":       {-5.5, 180},
+		"90,-180":        {90, -180},
+		"0,0":            {0, 0},
+	} {
+		lat, lng, err := ParseLatLng(s)
+		if err != nil || lat != c[0] || lng != c[1] {
+			t.Errorf("parse lat,lng: %s: %v %v %v", s, lat, lng, err)
+			return
+		}
+	}
+	for _, s := range []string{"41.115", "41.,-83", ".5,-83", "41.1,-83.",
+		"41.1, -83.1", "1234,5", "41.1,-83.1,5", "tiffin-oh", ""} {
+		lat, lng, err := ParseLatLng(s)
+		if err != ErrNotLatLng {
+			t.Errorf("parse lat,lng: %q: %v %v %v", s, lat, lng, err)
+			return
+		}
+	}
+	for _, s := range []string{"90.5,0", "-91,0", "0,180.01", "0,-200"} {
+		_, _, err := ParseLatLng(s)
+		if err == nil || err == ErrNotLatLng {
+			t.Errorf("parse lat,lng: %s: %v", s, err)
+			return
+		}
+	}
+}
+
+func TestFormatLatLng(t *testing.T) {
+	for s, ll := range map[string]string{
+		"41.11512345,-83.1771": "41.1151,-83.1771",
+		"41.1151,-83.1771":     "41.1151,-83.1771",
+		"41.115,-83.177":       "41.1150,-83.1770",
+		"5,-80":                "5.0000,-80.0000",
+		"-0.00001,-0.00001":    "0.0000,0.0000",
+		"-0.0001,-10.00001":    "-0.0001,-10.0000",
+	} {
+		lat, lng, err := ParseLatLng(s)
+		if err != nil {
+			t.Errorf("parse lat,lng: %s: %v", s, err)
+			return
+		}
+		if f := FormatLatLng(lat, lng); f != ll {
+			t.Errorf("format lat,lng: %s: %v", s, f)
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package geo
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Place slug regexes; for instance, oh/tiffin and tiffin-oh.
+var slugRegex = regexp.MustCompile(`^([a-z]{2})/([a-z0-9]+(?:-[a-z0-9]+)*)$`)
+var slugSuffixRegex = regexp.MustCompile(`^([a-z0-9]+(?:-[a-z0-9]+)*)-([a-z]{2})$`)
+
+// Returns the place name and the state code in place slug `s`; for
+// instance, bowling green and OH for oh/bowling-green or
+// bowling-green-oh. Returns false if `s` is not a place slug.
+func ParseSlug(s string) (string, string, bool) {
+	s = strings.ToLower(s)
+	name, state := "", ""
+	if m := slugRegex.FindStringSubmatch(s); m != nil {
+		name, state = m[2], m[1]
+	} else if m := slugSuffixRegex.FindStringSubmatch(s); m != nil {
+		name, state = m[1], m[2]
+	} else {
+		return "", "", false
+	}
+	state = strings.ToUpper(state)
+	if _, ok := states[state]; !ok {
+		return "", "", false
+	}
+	return strings.ReplaceAll(name, "-", " "), state, true
+}
+
+// Returns the canonical slug of place `name` in state `state`; for
+// instance, oh/bowling-green. Punctuation in `name` is dropped; for
+// instance, mo/st-louis for St. Louis.
+func Slug(name, state string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r == '-' || unicode.IsSpace(r):
+			return ' '
+		}
+		return -1
+	}, strings.ToLower(name))
+	name = strings.Join(strings.Fields(name), "-")
+	return strings.ToLower(state) + "/" + name
+}
+
+// Returns the name of place `c` without its state or country; for
+// instance, Tiffin for Tiffin, OH.
+func (c Coordinates) Place() string {
+	name, _, _ := strings.Cut(c.Name, ",")
+	return strings.TrimSpace(name)
+}
+
+// Returns true if place `c` is in state `state` of the US; for
+// instance, OH.
+func (c Coordinates) InState(state string) bool {
+	return c.CountryCode == "US" && c.State == state
+}
+
+// Returns the code of state `s`, which is either a state code or a
+// state name; an empty string if `s` is not a state.
+func StateCode(s string) string {
+	s = strings.TrimSpace(strings.ToLower(s))
+	for code, name := range states {
+		if s == strings.ToLower(code) || s == name {
+			return code
+		}
+	}
+	return ""
+}
+
+// States, the District of Columbia and Puerto Rico, by their codes.
+var states = map[string]string{
+	"AL": "alabama", "AK": "alaska", "AZ": "arizona", "AR": "arkansas",
+	"CA": "california", "CO": "colorado", "CT": "connecticut",
+	"DE": "delaware", "DC": "district of columbia", "FL": "florida",
+	"GA": "georgia", "HI": "hawaii", "ID": "idaho", "IL": "illinois",
+	"IN": "indiana", "IA": "iowa", "KS": "kansas", "KY": "kentucky",
+	"LA": "louisiana", "ME": "maine", "MD": "maryland",
+	"MA": "massachusetts", "MI": "michigan", "MN": "minnesota",
+	"MS": "mississippi", "MO": "missouri", "MT": "montana",
+	"NE": "nebraska", "NV": "nevada", "NH": "new hampshire",
+	"NJ": "new jersey", "NM": "new mexico", "NY": "new york",
+	"NC": "north carolina", "ND": "north dakota", "OH": "ohio",
+	"OK": "oklahoma", "OR": "oregon", "PA": "pennsylvania",
+	"RI": "rhode island", "SC": "south carolina", "SD": "south dakota",
+	"TN": "tennessee", "TX": "texas", "UT": "utah", "VT": "vermont",
+	"VA": "virginia", "WA": "washington", "WV": "west virginia",
+	"WI": "wisconsin", "WY": "wyoming", "PR": "puerto rico",
+}
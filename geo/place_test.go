@@ -0,0 +1,99 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package geo
+
+import "testing"
+
+func TestParseSlug(t *testing.T) {
+	for s, p := range map[string][2]string{
+		"oh/tiffin":           {"tiffin", "OH"},
+		"tiffin-oh":           {"tiffin", "OH"},
+		"OH/Tiffin":           {"tiffin", "OH"},
+		"oh/bowling-green":    {"bowling green", "OH"},
+		"bowling-green-oh":    {"bowling green", "OH"},
+		"ny/new-york":         {"new york", "NY"},
+		"mn/1000-lakes":       {"1000 lakes", "MN"},
+		"winston-salem-nc":    {"winston salem", "NC"},
+		"dc/washington":       {"washington", "DC"},
+		"pr/san-juan":         {"san juan", "PR"},
+		"district-heights-md": {"district heights", "MD"},
+	} {
+		name, state, ok := ParseSlug(s)
+		if !ok || name != p[0] || state != p[1] {
+			t.Errorf("parse slug: %s: %v %v %v", s, name, state, ok)
+			return
+		}
+	}
+	for _, s := range []string{"tiffin", "xx/tiffin", "tiffin-xx",
+		"oh/", "ohio/tiffin", "oh/tiffin/", "oh/tiffin--ohio", "-oh",
+		"version", "about", "41.1,-83.1", ""} {
+		name, state, ok := ParseSlug(s)
+		if ok {
+			t.Errorf("parse slug: %q: %v %v", s, name, state)
+			return
+		}
+	}
+}
+
+func TestSlug(t *testing.T) {
+	for name, slug := range map[string]string{
+		"Bowling  Green": "oh/bowling-green",
+		"St. Louis":      "oh/st-louis",
+		"Winston-Salem":  "oh/winston-salem",
+		"Coeur d'Alene":  "oh/coeur-dalene",
+	} {
+		if s := Slug(name, "OH"); s != slug {
+			t.Errorf("slug: %s: %v", name, s)
+			return
+		}
+		if _, _, ok := ParseSlug(Slug(name, "OH")); !ok {
+			t.Errorf("slug: %s: not parsed", name)
+			return
+		}
+	}
+	name, state, _ := ParseSlug("bowling-green-oh")
+	if s := Slug(name, state); s != "oh/bowling-green" {
+		t.Errorf("slug: round trip: %v", s)
+		return
+	}
+}
+
+func TestPlace(t *testing.T) {
+	for name, place := range map[string]string{
+		"Tiffin, OH":               "Tiffin",
+		"Tiffin, Ohio":             "Tiffin",
+		"Toronto, Ontario, Canada": "Toronto",
+		"Monaco":                   "Monaco",
+	} {
+		if p := (Coordinates{Name: name}).Place(); p != place {
+			t.Errorf("place: %s: %v", name, p)
+			return
+		}
+	}
+}
+
+func TestInState(t *testing.T) {
+	c := Coordinates{Name: "Tiffin, OH", State: "OH", CountryCode: "US"}
+	if !c.InState("OH") || c.InState("IA") {
+		t.Errorf("in state: %v", c)
+		return
+	}
+	c = Coordinates{Name: "Tbilisi, Georgia", CountryCode: "GE"}
+	if c.InState("GA") || c.InState("") {
+		t.Errorf("in state: outside us: %v", c)
+		return
+	}
+}
+
+func TestStateCode(t *testing.T) {
+	for s, code := range map[string]string{
+		"OH": "OH", "oh": "OH", "Ohio": "OH", " new york ": "NY",
+		"district of columbia": "DC", "ontario": "", "": "",
+	} {
+		if c := StateCode(s); c != code {
+			t.Errorf("state code: %q: %v", s, c)
+			return
+		}
+	}
+}
@@ -4,16 +4,29 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
-	"regexp"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"ricketyspace.net/peach/cache"
+	"ricketyspace.net/peach/discussion"
+	"ricketyspace.net/peach/gazetteer"
+	"ricketyspace.net/peach/geo"
+	"ricketyspace.net/peach/nws"
+	"ricketyspace.net/peach/photon"
 	"ricketyspace.net/peach/search"
+	"ricketyspace.net/peach/term"
 	"ricketyspace.net/peach/version"
 	"ricketyspace.net/peach/weather"
 )
@@ -21,39 +34,223 @@ import (
 // Peach port. Defaults to 8151
 var peachPort = flag.Int("p", 8151, "Port to run peach on")
 
+// Time budget for handling a request; this includes the time spent
+// on requests to weather.gov, open-meteo and photon.
+var peachBudget = flag.Duration("t", 15*time.Second,
+	"Time budget for handling a request")
+
+// Cache store for weather.gov responses; memory or file. Defaults to
+// $PEACH_CACHE or memory.
+var peachCache = flag.String("cache", envOr("PEACH_CACHE", "memory"),
+	"Cache store: memory or file")
+
+// Directory for the file cache store. Defaults to $PEACH_CACHE_DIR or
+// peach/ in the user's cache directory.
+var peachCacheDir = flag.String("cache-dir", os.Getenv("PEACH_CACHE_DIR"),
+	"Directory for the file cache store")
+
+// Limits for each of the memory cache stores.
+var peachCacheEntries = flag.Int("cache-entries", 1024,
+	"Maximum number of items in each memory cache store")
+var peachCacheSize = flag.Int("cache-size", 16,
+	"Maximum size, in MiB, of each cache store")
+
+// How long expired weather.gov responses are kept and served, while
+// they are refreshed, before they are dropped.
+var peachCacheGrace = flag.Duration("cache-grace", time.Hour,
+	"How long expired responses are kept in the cache stores")
+
+// Interval between sweeps of expired items from the memory cache
+// stores.
+const cacheSweepInterval = 10 * time.Minute
+
+// Geocoder for search and for naming places; photon, gazetteer or
+// none. Defaults to $PEACH_GEOCODER, or photon if $PEACH_PHOTON_URL
+// is set and gazetteer otherwise.
+var peachGeocoderName = flag.String("geocoder", envOr("PEACH_GEOCODER",
+	defaultGeocoder()), "Geocoder: photon, gazetteer or none")
+
+// Geocoder. Set during init; nil if geocoding is disabled.
+var peachGeocoder geo.Geocoder
+
+// Whether forecasts outside the US are from Open-Meteo.
+var peachGlobal = flag.Bool("global", true,
+	"Forecasts outside the US from Open-Meteo")
+
+// Provider of forecasts. Set during init.
+var peachProvider weather.Provider
+
 // Peach listen address. Set during init.
 var peachAddr = ""
 
 // Holds static content.
 //
-//go:embed templates static/peach.min.css
+//go:embed templates static/peach.min.css static/search.js
 //go:embed static/font/roboto-flex.ttf
 //go:embed static/logo/peach-*.png
+//go:embed static/icons/*.svg
 var peachFS embed.FS
 
 // HTML templates.
 var peachTemplates = template.Must(template.ParseFS(peachFS, "templates/*.tmpl"))
 
-// Lat,Long regex.
-var latLngRegex = regexp.MustCompile(`/(-?[0-9]+\.?[0-9]+?),(-?[0-9]+\.?[0-9]+)`)
+// Coordinates of the places that place slugs name, by their
+// canonical slugs.
+var slugCache = cache.NewBounded[string, *geo.Coordinates](1024, 0, nil)
+
+// How long places that place slugs name are cached.
+const slugExpiry = 24 * time.Hour
 
 func init() {
 	flag.Parse()
 	if *peachPort < 80 {
 		log.Fatalf("port number is invalid: %v", *peachPort)
 	}
+	if *peachBudget <= 0 {
+		log.Fatalf("time budget is invalid: %v", *peachBudget)
+	}
+	if *peachCacheGrace < 0 {
+		log.Fatalf("cache grace window is invalid: %v", *peachCacheGrace)
+	}
+	if *peachCacheEntries < 0 || *peachCacheSize < 0 {
+		log.Fatalf("cache limits are invalid: %v entries, %v MiB",
+			*peachCacheEntries, *peachCacheSize)
+	}
 	peachAddr = fmt.Sprintf(":%d", *peachPort)
+
+	err := setupCache()
+	if err != nil {
+		log.Fatalf("cache: %v", err)
+	}
+	err = setupGeocoder()
+	if err != nil {
+		log.Fatalf("geocoder: %v", err)
+	}
+	setupProvider()
+}
+
+// Sets up the provider of forecasts.
+func setupProvider() {
+	router := weather.Router{US: weather.NWS{}}
+	if *peachGlobal {
+		router.Global = weather.OpenMeteo{}
+	}
+	peachProvider = router
+}
+
+// Returns the name of the default geocoder.
+func defaultGeocoder() string {
+	if photon.Enabled() {
+		return "photon"
+	}
+	return "gazetteer"
+}
+
+// Returns true if the geocoder was set with -geocoder or
+// PEACH_GEOCODER, rather than defaulted.
+func geocoderChosen() bool {
+	chosen := len(os.Getenv("PEACH_GEOCODER")) > 0
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "geocoder" {
+			chosen = true
+		}
+	})
+	return chosen
+}
+
+// Sets up the geocoder.
+func setupGeocoder() error {
+	switch *peachGeocoderName {
+	case "photon":
+		if !photon.Enabled() {
+			return fmt.Errorf("photon: PEACH_PHOTON_URL is not set")
+		}
+		peachGeocoder = photon.Geocoder{}
+		return nil
+	case "gazetteer":
+		g, err := gazetteer.New()
+		if err != nil && !geocoderChosen() {
+			// peach still runs without the gazetteer data; it is
+			// built by make.
+			log.Printf("geocoder: %v; search is disabled", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if photon.Enabled() {
+			g.SetZipFallback(photon.Geocoder{})
+		}
+		peachGeocoder = g
+		return nil
+	case "none":
+		return nil
+	}
+	return fmt.Errorf("geocoder is invalid: %v", *peachGeocoderName)
+}
+
+// Sets up the cache store for weather.gov responses.
+func setupCache() error {
+	nws.LimitDecoded(*peachCacheSize << 20)
+	switch *peachCache {
+	case "memory":
+		return nws.UseStores(func(name string) (cache.Store, error) {
+			c := cache.NewBoundedCache(*peachCacheEntries,
+				*peachCacheSize<<20)
+			c.SetGrace(*peachCacheGrace)
+			c.StartJanitor(cacheSweepInterval)
+			return c, nil
+		})
+	case "file":
+		dir := *peachCacheDir
+		if len(dir) == 0 {
+			userDir, err := os.UserCacheDir()
+			if err != nil {
+				return err
+			}
+			dir = filepath.Join(userDir, "peach")
+		}
+		return nws.UseStores(func(name string) (cache.Store, error) {
+			s, err := cache.NewFileStore(filepath.Join(dir, "nws", name))
+			if err != nil {
+				return nil, err
+			}
+			s.SetGrace(*peachCacheGrace)
+			s.SetMaxBytes(int64(*peachCacheSize) << 20)
+			s.StartJanitor(cacheSweepInterval)
+			return s, nil
+		})
+	}
+	return fmt.Errorf("store is invalid: %v", *peachCache)
+}
+
+// Returns the value of environment variable `key`; `fallback` if it
+// is not set.
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); len(v) > 0 {
+		return v
+	}
+	return fallback
 }
 
 func main() {
 	// Default handler.
-	http.HandleFunc("/", defaultHandler)
+	http.HandleFunc("/", budget(defaultHandler))
 
 	// Static files handler.
 	http.HandleFunc("/static/", serveStaticFile)
 
 	// Search handler.
-	http.HandleFunc("/search", showSearch)
+	http.HandleFunc("/search", budget(showSearch))
+
+	// Forecast discussion handler.
+	http.HandleFunc("/discussion/", budget(showDiscussion))
+
+	// API handler.
+	http.HandleFunc("/api/v1/", budget(apiHandler))
+
+	// Search suggestions handler.
+	http.HandleFunc("/api/search", budget(apiSearchHandler))
 
 	// Meta handler.
 	http.HandleFunc("/about", showMeta)
@@ -62,9 +259,19 @@ func main() {
 	log.Fatal(http.ListenAndServe(peachAddr, nil))
 }
 
+// Wraps handler `h` such that the request's context is done once the
+// time budget for the request is spent.
+func budget(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), *peachBudget)
+		defer cancel()
+		h(w, r.WithContext(ctx))
+	}
+}
+
 func defaultHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == "/" {
-		http.Redirect(w, r, "/41.115,-83.177", 302)
+		http.Redirect(w, r, "/41.1150,-83.1770", 302)
 		return
 	}
 	if r.URL.Path == "/version" {
@@ -72,27 +279,119 @@ func defaultHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	m := latLngRegex.FindStringSubmatch(r.URL.Path)
-	if len(m) != 3 || m[0] != r.URL.Path {
-		http.NotFound(w, r)
+	// Coordinates; for instance, /41.115,-83.177.
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	lat, lng, err := geo.ParseLatLng(path)
+	if err == nil {
+		// Redirect to the coordinates on the grid that weather.gov
+		// responses are cached on.
+		if ll := geo.FormatLatLng(lat, lng); path != ll {
+			u := *r.URL
+			u.Path = "/" + ll
+			http.Redirect(w, r, u.String(), 301)
+			return
+		}
+		showWeather(w, r, lat, lng)
 		return
 	}
-	lat, err := strconv.ParseFloat(m[1], 32)
+	if err != geo.ErrNotLatLng {
+		showError(w, r, err, 400)
+		return
+	}
+
+	// Place slug; for instance, /oh/tiffin or /tiffin-oh.
+	name, state, ok := geo.ParseSlug(path)
+	if !ok {
+		showError(w, r, fmt.Errorf("%s not found", r.URL.Path), 404)
+		return
+	}
+	slug := geo.Slug(name, state)
+	if path != slug {
+		u := *r.URL
+		u.Path = "/" + slug
+		http.Redirect(w, r, u.String(), 301)
+		return
+	}
+	c, err, status := findPlace(r.Context(), name, state)
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		showError(w, r, err, status)
+		return
 	}
-	lng, err := strconv.ParseFloat(m[2], 32)
+
+	// Redirect to the slug of the place that was found; for
+	// instance, oh/tif to oh/tiffin.
+	if s := geo.Slug(c.Place(), state); s != slug {
+		u := *r.URL
+		u.Path = "/" + s
+		http.Redirect(w, r, u.String(), 301)
+		return
+	}
+	showWeather(w, r, c.Lat, c.Lng)
+}
+
+// Returns the coordinates of place `name` in state `state`. The place
+// may be named a little differently; for instance, Tiffin for tif.
+func findPlace(ctx context.Context, name, state string) (*geo.Coordinates, error, int) {
+	slug := geo.Slug(name, state)
+	if c := slugCache.Get(slug); c != nil {
+		return c, nil, 200
+	}
+	if peachGeocoder == nil {
+		return nil, fmt.Errorf("places cannot be looked up"), 404
+	}
+	mCoords, err := peachGeocoder.Geocode(ctx, fmt.Sprintf("%s, %s", name, state))
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		log.Printf("place: %s: %v", slug, err)
+		return nil, fmt.Errorf("unable to lookup %s, %s", name, state), 502
 	}
-	showWeather(w, float32(lat), float32(lng))
+
+	// The geocoder may find places of the same name elsewhere, and
+	// places whose names only start like or look like `name`. The
+	// place whose slug is `slug` comes first.
+	var c *geo.Coordinates
+	for i := range mCoords {
+		if !mCoords[i].InState(state) {
+			continue
+		}
+		if geo.Slug(mCoords[i].Place(), state) == slug {
+			c = &mCoords[i]
+			break
+		}
+		if c == nil {
+			c = &mCoords[i]
+		}
+	}
+	if c == nil {
+		return nil, fmt.Errorf("%s, %s not found", name, state), 404
+	}
+	slugCache.Set(slug, c, time.Now().Add(slugExpiry))
+	return c, nil, 200
 }
 
-func showWeather(w http.ResponseWriter, lat, lng float32) {
+func showWeather(w http.ResponseWriter, r *http.Request, lat, lng float32) {
+	o, err := weather.ParseOptions(r.URL.Query())
+	if err != nil {
+		showError(w, r, err, 400)
+		return
+	}
+
 	// Make weather
-	weather, err, status := weather.NewWeather(lat, lng)
+	weather, err, status := weather.NewWeather(r.Context(), peachProvider,
+		peachGeocoder, lat, lng, o)
 	if err != nil {
-		http.Error(w, err.Error(), status)
+		showError(w, r, err, status)
+		return
+	}
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="canonical"`, weather.Canonical))
+
+	// Render plain text for terminals.
+	if wantsText(r) {
+		color, _ := strconv.ParseBool(r.URL.Query().Get("color"))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err = term.Render(w, weather, color)
+		if err != nil {
+			log.Printf("weather: text: %v", err)
+		}
 		return
 	}
 
@@ -104,15 +403,156 @@ func showWeather(w http.ResponseWriter, lat, lng float32) {
 	}
 }
 
+// Returns true if the weather should be rendered as plain text;
+// either because it was asked for with `?format=text` or because the
+// request is from a command line HTTP client.
+func wantsText(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "text":
+		return true
+	case "html":
+		return false
+	}
+	ua := strings.ToLower(r.Header.Get("User-Agent"))
+	for _, client := range []string{"curl/", "wget/", "httpie/"} {
+		if strings.HasPrefix(ua, client) {
+			return true
+		}
+	}
+	return false
+}
+
+func apiHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+	lat, lng, err := geo.ParseLatLng(path)
+	if err == geo.ErrNotLatLng {
+		writeJSONError(w, fmt.Errorf("%s not found", r.URL.Path), 404)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err, 400)
+		return
+	}
+
+	o, err := weather.ParseOptions(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, err, 400)
+		return
+	}
+
+	// Make weather
+	weather, err, status := weather.NewWeather(r.Context(), peachProvider,
+		peachGeocoder, lat, lng, o)
+	if err != nil {
+		writeJSONError(w, err, status)
+		return
+	}
+	w.Header().Set("Link", fmt.Sprintf(`</api/v1/%s>; rel="canonical"`,
+		geo.FormatLatLng(lat, lng)))
+	writeJSON(w, weather, 200)
+}
+
+func apiSearchHandler(w http.ResponseWriter, r *http.Request) {
+	mCoords, err, status := search.Suggest(r.Context(), peachGeocoder,
+		r.URL.Query().Get("q"))
+	if err != nil {
+		writeJSONError(w, err, status)
+		return
+	}
+	w.Header().Set("Cache-Control", "max-age=3600")
+	writeJSON(w, mCoords, 200)
+}
+
+// Writes `v` as JSON to the response.
+func writeJSON(w http.ResponseWriter, v any, status int) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeJSONError(w, err, 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// Writes `err` as a JSON problem to the response. The problem has the
+// same shape as the errors returned by the NWS API.
+func writeJSONError(w http.ResponseWriter, err error, status int) {
+	p := &nws.Error{}
+	if !errors.As(err, &p) {
+		p = &nws.Error{
+			Title:  http.StatusText(status),
+			Type:   "peach-error",
+			Status: status,
+			Detail: err.Error(),
+		}
+	}
+	if p.Status == 0 {
+		p.Status = status
+	}
+	body, jErr := json.Marshal(p)
+	if jErr != nil {
+		http.Error(w, jErr.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// Renders error `err` as a page with status `status`; as plain text
+// for terminals.
+func showError(w http.ResponseWriter, r *http.Request, err error, status int) {
+	if wantsText(r) {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	type Error struct {
+		Version   string
+		Title     string
+		Canonical string
+		Message   string
+	}
+	e := new(Error)
+	e.Version = version.Version
+	e.Title = strings.ToLower(http.StatusText(status))
+	e.Message = err.Error()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	tErr := peachTemplates.ExecuteTemplate(w, "error.tmpl", e)
+	if tErr != nil {
+		log.Printf("error: template: %v", tErr)
+		return
+	}
+}
+
+func showDiscussion(w http.ResponseWriter, r *http.Request) {
+	wfo := strings.TrimPrefix(r.URL.Path, "/discussion/")
+	discussion, err, status := discussion.NewDiscussion(r.Context(), wfo)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	err = peachTemplates.ExecuteTemplate(w, "discussion.tmpl", discussion)
+	if err != nil {
+		log.Printf("discussion: template: %v", err)
+		return
+	}
+}
+
 func showMeta(w http.ResponseWriter, r *http.Request) {
 	// Make meta info.
 	type Meta struct {
-		Version string
-		Title   string
+		Version   string
+		Title     string
+		Canonical string
 	}
 	m := new(Meta)
 	m.Version = version.Version
 	m.Title = "about"
+	m.Canonical = "/about"
 
 	// Render.
 	err := peachTemplates.ExecuteTemplate(w, "about.tmpl", m)
@@ -132,7 +572,7 @@ func serveStaticFile(w http.ResponseWriter, r *http.Request) {
 }
 
 func showSearch(w http.ResponseWriter, r *http.Request) {
-	search, err, status := search.NewSearch(r)
+	search, err, status := search.NewSearch(r, peachGeocoder)
 	if err != nil && status == 404 {
 		http.NotFound(w, r)
 		return
@@ -0,0 +1,163 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package nws
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ricketyspace.net/peach/cache"
+)
+
+// Identifies an item in one of the cache stores.
+type flightKey struct {
+	store cache.Store
+	key   string
+}
+
+// A request to weather.gov that is in flight.
+type flight struct {
+	done    chan int // Closed when the request is done.
+	body    []byte
+	expires time.Time
+	err     *Error
+}
+
+// Requests in flight.
+var flights = struct {
+	sema  chan int // Semaphore for read/write access to the flights.
+	calls map[flightKey]*flight
+}{
+	sema:  make(chan int, 1),
+	calls: make(map[flightKey]*flight),
+}
+
+// A decoded response.
+type decodedValue[V any] struct {
+	value V
+	size  int // Size of the response it was decoded from.
+}
+
+// Time budget for refreshing a stale item in the background.
+const refreshTimeout = 30 * time.Second
+
+// Returns the body of the response from `url` that is cached in
+// `store` under `key`. On a cache miss, the response is fetched from
+// weather.gov and cached.
+//
+// Also returns the time the response expires. If the cached response
+// expired but is still in the store's grace window, it is returned
+// right away and refreshed in the background.
+//
+// Concurrent misses for the same key share a single request and its
+// result. If the shared request is cut short by the context of the
+// caller that made it, the other callers make their own request.
+func fetch(ctx context.Context, store cache.Store, key, url string) ([]byte, time.Time, *Error) {
+	fk := flightKey{store, key}
+	for {
+		body, expires := store.GetStale(key)
+		if len(body) > 0 {
+			if time.Until(expires).Seconds() < 0 {
+				go refresh(fk, url)
+			}
+			return body, expires, nil
+		}
+
+		// Join the request in flight or start a new one.
+		f, joined := join(fk)
+		if !joined {
+			f.run(ctx, fk, url)
+			return f.body, f.expires, f.err
+		}
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, time.Time{}, contextError(ctx, url, nil)
+		}
+		if f.err != nil && (f.err.Type == "request-timeout" ||
+			f.err.Type == "request-canceled") && ctx.Err() == nil {
+			continue // Not our context; try again.
+		}
+		return f.body, f.expires, f.err
+	}
+}
+
+// Returns the response from `url` that is cached in `store` under
+// `key`, decoded by `decode`; see fetch. Also returns the time the
+// response expired if it is stale; zero otherwise.
+//
+// Decoded responses are kept in `values` until they expire, so that
+// a cache hit does not decode the response again. They are shared by
+// all callers and must not be modified. Stale responses are decoded
+// for each caller and are not kept.
+func fetchDecoded[V any](ctx context.Context, store cache.Store,
+	values *cache.Cache[string, decodedValue[V]], key, url string,
+	decode func(body []byte) (V, *Error)) (V, time.Time, *Error) {
+	var zero V
+	if d, expires := values.GetStale(key); time.Until(expires).Seconds() > 0 {
+		return d.value, time.Time{}, nil
+	}
+
+	body, expires, nwsErr := fetch(ctx, store, key, url)
+	if nwsErr != nil {
+		return zero, time.Time{}, nwsErr
+	}
+	v, nwsErr := decode(body)
+	if nwsErr != nil {
+		return zero, time.Time{}, nwsErr
+	}
+	if time.Until(expires).Seconds() < 0 {
+		return v, expires, nil // Stale.
+	}
+	values.Set(key, decodedValue[V]{v, len(body)}, expires)
+	return v, time.Time{}, nil
+}
+
+// Refreshes the stale item `fk` from `url`, unless it is already
+// being fetched. The stale item is kept if the refresh fails.
+func refresh(fk flightKey, url string) {
+	f, joined := join(fk)
+	if joined {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+	f.run(ctx, fk, url)
+	if f.err != nil {
+		log.Printf("nws: refresh: %v", f.err)
+	}
+}
+
+// Returns the request in flight for `fk`; true if it was already in
+// flight. If it was not, the caller must run the returned request.
+func join(fk flightKey) (*flight, bool) {
+	flights.sema <- 1
+	defer func() {
+		<-flights.sema
+	}()
+
+	f, joined := flights.calls[fk]
+	if !joined {
+		f = &flight{done: make(chan int)}
+		flights.calls[fk] = f
+	}
+	return f, joined
+}
+
+// Fetches `url`, caches the response as item `fk` and lets the
+// callers that joined the request know that it is done.
+func (f *flight) run(ctx context.Context, fk flightKey, url string) {
+	f.body, f.expires, f.err = get(ctx, url)
+	if f.err == nil {
+		// Cache it.
+		fk.store.Set(fk.key, f.body, f.expires)
+	}
+
+	flights.sema <- 1
+	delete(flights.calls, fk)
+	<-flights.sema
+	close(f.done)
+}
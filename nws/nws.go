@@ -5,14 +5,17 @@
 package nws
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
+	"sync"
 	"time"
 
 	"ricketyspace.net/peach/cache"
 	"ricketyspace.net/peach/client"
+	t "ricketyspace.net/peach/time"
 )
 
 type PointLocationProperties struct {
@@ -32,6 +35,7 @@ type PointProperties struct {
 	ForecastHourly   string
 	ForecastGridData string
 	RelativeLocation PointLocation
+	TimeZone         string
 }
 
 type Point struct {
@@ -49,8 +53,12 @@ type ForecastPeriod struct {
 	TemperatureTrend string
 	WindSpeed        string
 	WindDirection    string
+	Icon             string // Link to the icon of the forecast.
 	ShortForecast    string
 	DetailedForecast string
+
+	// Value is nil when the chance of precipitation is not given.
+	ProbabilityOfPrecipitation QuantitativeValue
 }
 
 type ForecastProperties struct {
@@ -60,6 +68,10 @@ type ForecastProperties struct {
 
 type Forecast struct {
 	Properties ForecastProperties
+
+	// When the data is a stale copy from the cache, the time the copy
+	// expired; zero otherwise.
+	StaleSince time.Time `json:"-"`
 }
 
 type FeatureProperties struct {
@@ -76,31 +88,194 @@ type Feature struct {
 
 type FeatureCollection struct {
 	Features []Feature
+
+	// When the data is a stale copy from the cache, the time the copy
+	// expired; zero otherwise.
+	StaleSince time.Time `json:"-"`
 }
 
 type ForecastGrid struct {
 	Properties GridProperties
+
+	// When the data is a stale copy from the cache, the time the copy
+	// expired; zero otherwise.
+	StaleSince time.Time `json:"-"`
 }
 
+// Gridpoint forecast layers. The numeric layers are in the unit of
+// measure given by their `Uom`; for instance, wmoUnit:degC.
 type GridProperties struct {
-	RelativeHumidity GridHumidity
+	UpdateTime                       string
+	ValidTimes                       string
+	Elevation                        QuantitativeValue
+	ForecastOffice                   string
+	GridId                           string
+	GridX                            int
+	GridY                            int
+	Temperature                      GridLayer[*float64]
+	Dewpoint                         GridLayer[*float64]
+	MaxTemperature                   GridLayer[*float64]
+	MinTemperature                   GridLayer[*float64]
+	RelativeHumidity                 GridLayer[*float64]
+	ApparentTemperature              GridLayer[*float64]
+	WetBulbGlobeTemperature          GridLayer[*float64]
+	HeatIndex                        GridLayer[*float64]
+	WindChill                        GridLayer[*float64]
+	SkyCover                         GridLayer[*float64]
+	WindDirection                    GridLayer[*float64]
+	WindSpeed                        GridLayer[*float64]
+	WindGust                         GridLayer[*float64]
+	Weather                          GridLayer[[]GridWeather]
+	Hazards                          GridLayer[[]GridHazard]
+	ProbabilityOfPrecipitation       GridLayer[*float64]
+	QuantitativePrecipitation        GridLayer[*float64]
+	IceAccumulation                  GridLayer[*float64]
+	SnowfallAmount                   GridLayer[*float64]
+	SnowLevel                        GridLayer[*float64]
+	CeilingHeight                    GridLayer[*float64]
+	Visibility                       GridLayer[*float64]
+	TransportWindSpeed               GridLayer[*float64]
+	TransportWindDirection           GridLayer[*float64]
+	MixingHeight                     GridLayer[*float64]
+	HainesIndex                      GridLayer[*float64]
+	LightningActivityLevel           GridLayer[*float64]
+	TwentyFootWindSpeed              GridLayer[*float64]
+	TwentyFootWindDirection          GridLayer[*float64]
+	WaveHeight                       GridLayer[*float64]
+	WavePeriod                       GridLayer[*float64]
+	WaveDirection                    GridLayer[*float64]
+	PrimarySwellHeight               GridLayer[*float64]
+	PrimarySwellDirection            GridLayer[*float64]
+	SecondarySwellHeight             GridLayer[*float64]
+	SecondarySwellDirection          GridLayer[*float64]
+	WavePeriod2                      GridLayer[*float64]
+	WindWaveHeight                   GridLayer[*float64]
+	DispersionIndex                  GridLayer[*float64]
+	Pressure                         GridLayer[*float64]
+	ProbabilityOfTropicalStormWinds  GridLayer[*float64]
+	ProbabilityOfHurricaneWinds      GridLayer[*float64]
+	PotentialOf15mphWinds            GridLayer[*float64]
+	PotentialOf25mphWinds            GridLayer[*float64]
+	PotentialOf35mphWinds            GridLayer[*float64]
+	PotentialOf45mphWinds            GridLayer[*float64]
+	PotentialOf20mphWindGusts        GridLayer[*float64]
+	PotentialOf30mphWindGusts        GridLayer[*float64]
+	PotentialOf40mphWindGusts        GridLayer[*float64]
+	PotentialOf50mphWindGusts        GridLayer[*float64]
+	PotentialOf60mphWindGusts        GridLayer[*float64]
+	GrasslandFireDangerIndex         GridLayer[*float64]
+	ProbabilityOfThunder             GridLayer[*float64]
+	DavisStabilityIndex              GridLayer[*float64]
+	AtmosphericDispersionIndex       GridLayer[*float64]
+	LowVisibilityOccurrenceRiskIndex GridLayer[*float64]
+	Stability                        GridLayer[*float64]
+	RedFlagThreatIndex               GridLayer[*float64]
 }
 
-type GridHumidity struct {
+// A gridpoint layer. It is a time series of values that are in the
+// unit of measure `Uom`.
+type GridLayer[T any] struct {
 	Uom    string
-	Values []GridHumidityValue
+	Values []GridValue[T]
 }
 
-type GridHumidityValue struct {
+// A value in a gridpoint layer. `ValidTime` is the ISO 8601 time
+// interval, in 2022-08-07T02:00:00+00:00/PT3H format, during which
+// the value is valid.
+//
+// For the numeric layers, `Value` is nil when NWS has no data for the
+// interval.
+type GridValue[T any] struct {
 	ValidTime string
-	Value     int
+	Value     T
+}
+
+// A dense hourly time series; Values[i] is the value for the hour
+// that starts at Start + i hours.
+type HourlySeries[T any] struct {
+	Start  time.Time
+	Values []T
+}
+
+// A value in the weather layer.
+type GridWeather struct {
+	Coverage   string
+	Weather    string
+	Intensity  string
+	Visibility QuantitativeValue
+	Attributes []string
+}
+
+// A value in the hazards layer.
+type GridHazard struct {
+	Phenomenon   string
+	Significance string
+	EventNumber  int `json:"event_number"`
+}
+
+// A measurement in the unit `UnitCode`; for instance, wmoUnit:m.
+type QuantitativeValue struct {
+	UnitCode string
+	Value    *float64
+}
+
+type StationProperties struct {
+	StationIdentifier string
+	Name              string
+}
+
+type Station struct {
+	Properties StationProperties
+}
+
+// Observation stations; nearest first.
+type Stations struct {
+	Features []Station
+}
+
+// Observation measurements. Measurements that are missing have a nil
+// Value.
+type ObservationProperties struct {
+	Station            string
+	Timestamp          string
+	TextDescription    string
+	Temperature        QuantitativeValue
+	Dewpoint           QuantitativeValue
+	WindDirection      QuantitativeValue
+	WindSpeed          QuantitativeValue
+	WindGust           QuantitativeValue
+	BarometricPressure QuantitativeValue
+	SeaLevelPressure   QuantitativeValue
+	Visibility         QuantitativeValue
+	RelativeHumidity   QuantitativeValue
+	WindChill          QuantitativeValue
+	HeatIndex          QuantitativeValue
+}
+
+type Observation struct {
+	Properties ObservationProperties
+}
+
+// A NWS text product.
+type Product struct {
+	Id            string
+	IssuingOffice string
+	IssuanceTime  string
+	ProductCode   string
+	ProductName   string
+	ProductText   string // Empty in product lists.
+}
+
+// A list of NWS text products; latest first.
+type Products struct {
+	Graph []Product `json:"@graph"`
 }
 
 type Error struct {
-	Title  string
-	Type   string
-	Status int
-	Detail string
+	Title  string `json:"title"`
+	Type   string `json:"type"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
 }
 
 // NWS Forecast bundle.
@@ -110,23 +285,55 @@ type ForecastBundle struct {
 	ForecastHourly *Forecast
 	ForecastGrid   *ForecastGrid
 	Alerts         *FeatureCollection
+	Observation    *Observation
+
+	// Errors from fetching each component; nil if the component
+	// was fetched.
+	ForecastErr       *Error
+	ForecastHourlyErr *Error
+	ForecastGridErr   *Error
+	AlertsErr         *Error
+	ObservationErr    *Error
 }
 
-var pCache *cache.Cache
-var fCache *cache.Cache
-var fhCache *cache.Cache
-var fgCache *cache.Cache
-var aCache *cache.Cache
+var pCache cache.Store
+var fCache cache.Store
+var fhCache cache.Store
+var fgCache cache.Store
+var aCache cache.Store
+var sCache cache.Store
+var oCache cache.Store
+var plCache cache.Store
+var prCache cache.Store
+
+// Decoded responses from the stores above, so that a cache hit does
+// not decode the response again.
+var pValues *cache.Cache[string, decodedValue[*Point]]
+var fValues *cache.Cache[string, decodedValue[*Forecast]]
+var fhValues *cache.Cache[string, decodedValue[*Forecast]]
+var fgValues *cache.Cache[string, decodedValue[*ForecastGrid]]
+var aValues *cache.Cache[string, decodedValue[*FeatureCollection]]
+var sValues *cache.Cache[string, decodedValue[*Stations]]
+var oValues *cache.Cache[string, decodedValue[*Observation]]
+var plValues *cache.Cache[string, decodedValue[*Products]]
+var prValues *cache.Cache[string, decodedValue[*Product]]
+
+// Maximum number of decoded responses of each kind that are kept.
+const decodedEntries = 256
+
+// Maximum size, in bytes, of the decoded responses of each kind that
+// are kept; zero if unbounded. See LimitDecoded.
+var decodedBytes = 0
+
 var baseUrl *url.URL
 
 func init() {
 	var err error
 
-	pCache = cache.NewCache()
-	fCache = cache.NewCache()
-	fhCache = cache.NewCache()
-	fgCache = cache.NewCache()
-	aCache = cache.NewCache()
+	// Cache in memory by default.
+	UseStores(func(string) (cache.Store, error) {
+		return cache.NewCache(), nil
+	})
 
 	// Parse NWS base url.
 	baseUrl, err = url.Parse("https://api.weather.gov")
@@ -135,99 +342,162 @@ func init() {
 	}
 }
 
+// Sets the stores that NWS responses are cached in. `newStore` is
+// called once for each kind of response with the kind's name; for
+// instance, "points" or "forecast-hourly".
+//
+// Must be called before any of the NWS endpoints are used.
+func UseStores(newStore func(name string) (cache.Store, error)) error {
+	stores := []struct {
+		name  string
+		store *cache.Store
+	}{
+		{"points", &pCache},
+		{"forecast", &fCache},
+		{"forecast-hourly", &fhCache},
+		{"forecast-grid", &fgCache},
+		{"alerts", &aCache},
+		{"stations", &sCache},
+		{"observations", &oCache},
+		{"product-lists", &plCache},
+		{"products", &prCache},
+	}
+	for _, s := range stores {
+		store, err := newStore(s.name)
+		if err != nil {
+			return fmt.Errorf("store: %s: %v", s.name, err)
+		}
+		*s.store = store
+	}
+
+	// Drop the decoded responses from the old stores.
+	resetValues()
+	return nil
+}
+
+// Keeps at most `maxBytes` bytes of decoded responses of each kind; a
+// limit of zero means no limit. The size of a decoded response is
+// taken to be the size of the response it was decoded from.
+//
+// Must be called before any of the NWS endpoints are used.
+func LimitDecoded(maxBytes int) {
+	decodedBytes = maxBytes
+	resetValues()
+}
+
+// Makes new empty caches for decoded responses.
+func resetValues() {
+	pValues = newValues[*Point]()
+	fValues = newValues[*Forecast]()
+	fhValues = newValues[*Forecast]()
+	fgValues = newValues[*ForecastGrid]()
+	aValues = newValues[*FeatureCollection]()
+	sValues = newValues[*Stations]()
+	oValues = newValues[*Observation]()
+	plValues = newValues[*Products]()
+	prValues = newValues[*Product]()
+}
+
+// Returns a new empty cache for decoded responses of type V.
+func newValues[V any]() *cache.Cache[string, decodedValue[V]] {
+	return cache.NewBounded(decodedEntries, decodedBytes,
+		func(k string, d decodedValue[V]) int {
+			return len(k) + d.size
+		})
+}
+
 func (e Error) Error() string {
 	return fmt.Sprintf("%d: %s: %s", e.Status, e.Type, e.Detail)
 }
 
-// Gets NWS's forecast and hourly forecast.
-func GetForecastBundle(lat, lng float32) (*ForecastBundle, *Error) {
-	p, nwsErr := Points(lat, lng)
+// Gets NWS's forecast and hourly forecast along with the grid data,
+// the active alerts and the latest observation from the nearest
+// station.
+//
+// Once the point is resolved, the rest are fetched concurrently. The
+// forecast and the hourly forecast are essential; an error is
+// returned if either of them cannot be fetched. The other components
+// are left nil when they cannot be fetched and their error is set in
+// the bundle.
+func GetForecastBundle(ctx context.Context, lat, lng float32) (*ForecastBundle, *Error) {
+	p, nwsErr := Points(ctx, lat, lng)
 	if nwsErr != nil {
 		return nil, nwsErr
 	}
 
-	f, nwsErr := GetForecast(p)
-	if nwsErr != nil {
-		return nil, nwsErr
-	}
+	b := &ForecastBundle{Point: p}
+	var wg sync.WaitGroup
+	wg.Add(5)
+	go func() {
+		defer wg.Done()
+		b.Forecast, b.ForecastErr = GetForecast(ctx, p)
+	}()
+	go func() {
+		defer wg.Done()
+		b.ForecastHourly, b.ForecastHourlyErr = GetForecastHourly(ctx, p)
+	}()
+	go func() {
+		defer wg.Done()
+		b.ForecastGrid, b.ForecastGridErr = GetForecastGridData(ctx, p)
+	}()
+	go func() {
+		defer wg.Done()
+		b.Alerts, b.AlertsErr = GetAlerts(ctx, lat, lng)
+	}()
+	go func() {
+		defer wg.Done()
+		b.Observation, b.ObservationErr = getNearestObservation(ctx, p)
+	}()
+	wg.Wait()
 
-	fh, nwsErr := GetForecastHourly(p)
-	if nwsErr != nil {
-		return nil, nwsErr
+	if b.ForecastErr != nil {
+		return nil, b.ForecastErr
 	}
-
-	a, nwsErr := GetAlerts(lat, lng)
-	if nwsErr != nil {
-		return nil, nwsErr
+	if b.ForecastHourlyErr != nil {
+		return nil, b.ForecastHourlyErr
 	}
+	return b, nil
+}
 
-	g, nwsErr := GetForecastGridData(p)
+// Gets the latest observation from the station nearest to `point`.
+func getNearestObservation(ctx context.Context, point *Point) (*Observation, *Error) {
+	s, nwsErr := GetStations(ctx, point)
 	if nwsErr != nil {
 		return nil, nwsErr
 	}
-
-	return &ForecastBundle{
-		Point:          p,
-		Forecast:       f,
-		ForecastHourly: fh,
-		ForecastGrid:   g,
-		Alerts:         a,
-	}, nil
+	if len(s.Features) < 1 {
+		return nil, &Error{
+			Title:  "no observation stations",
+			Type:   "stations-empty",
+			Status: 404,
+			Detail: "no observation stations near point",
+		}
+	}
+	return GetLatestObservation(ctx, s.Features[0].Properties.StationIdentifier)
 }
 
 // NWS `/points` endpoint.
-func Points(lat, lng float32) (*Point, *Error) {
-	var nwsErr *Error
-	var expires time.Time
-	var body []byte
-
+func Points(ctx context.Context, lat, lng float32) (*Point, *Error) {
 	ll := fmt.Sprintf("%.4f,%.4f", lat, lng)
-	if body = pCache.Get(ll); len(body) == 0 {
-		url := fmt.Sprintf("https://api.weather.gov/points/%s", ll)
-		body, expires, nwsErr = get(url)
-		if nwsErr != nil {
-			return nil, nwsErr
-		}
-		// Cache it.
-		pCache.Set(ll, body, expires)
-	}
-
-	// Unmarshal.
-	point := new(Point)
-	err := json.Unmarshal(body, point)
-	if err != nil {
-		return nil, &Error{
-			Title:  "unable json unmarshal",
-			Type:   "points-json-error",
-			Status: 500,
-			Detail: err.Error(),
-		}
-	}
-	if point.Properties.Forecast == "" {
+	u, uErr := baseUrl.Parse("/points/" + ll)
+	if uErr != nil {
 		return nil, &Error{
-			Title:  "forecast empty",
-			Type:   "points-forecast-error",
+			Title:  "points url parsing failed",
+			Type:   "url-parse-error",
 			Status: 500,
-			Detail: "forecast is empty",
+			Detail: uErr.Error(),
 		}
 	}
-	if point.Properties.ForecastHourly == "" {
-		return nil, &Error{
-			Title:  "forecast hourly empty",
-			Type:   "points-forecast-error",
-			Status: 500,
-			Detail: "forecast  hourly is empty",
-		}
+	point, _, nwsErr := fetchDecoded(ctx, pCache, pValues,
+		ll, u.String(), decodePoint)
+	if nwsErr != nil {
+		return nil, nwsErr
 	}
 	return point, nil
 }
 
 // NWS forecast endpoint.
-func GetForecast(point *Point) (*Forecast, *Error) {
-	var nwsErr *Error
-	var expires time.Time
-	var body []byte
-
+func GetForecast(ctx context.Context, point *Point) (*Forecast, *Error) {
 	if point == nil {
 		return nil, &Error{
 			Title:  "point is nil",
@@ -245,44 +515,19 @@ func GetForecast(point *Point) (*Forecast, *Error) {
 		}
 	}
 
-	if body = fCache.Get(point.Properties.Forecast); len(body) == 0 {
-		// Get the forecast
-		body, expires, nwsErr = get(point.Properties.Forecast)
-		if nwsErr != nil {
-			return nil, nwsErr
-		}
-		// Cache it.
-		fCache.Set(point.Properties.Forecast, body, expires)
-	}
-
-	// Unmarshal.
-	forecast := new(Forecast)
-	err := json.Unmarshal(body, forecast)
-	if err != nil {
-		return nil, &Error{
-			Title:  "forecast json unmarshal failed",
-			Type:   "forecast-json-error",
-			Status: 500,
-			Detail: "forecast json unmarshal failed",
-		}
+	forecast, stale, nwsErr := fetchDecoded(ctx, fCache, fValues,
+		point.Properties.Forecast, point.Properties.Forecast, decodeForecast)
+	if nwsErr != nil {
+		return nil, nwsErr
 	}
-	if len(forecast.Properties.Periods) == 0 {
-		return nil, &Error{
-			Title:  "forecast has no periods",
-			Type:   "forecast-periods-empty",
-			Status: 500,
-			Detail: "forecast has no periods",
-		}
+	if !stale.IsZero() {
+		forecast.StaleSince = stale
 	}
 	return forecast, nil
 }
 
 // NWS forecast hourly endpoint.
-func GetForecastHourly(point *Point) (*Forecast, *Error) {
-	var nwsErr *Error
-	var expires time.Time
-	body := []byte{}
-
+func GetForecastHourly(ctx context.Context, point *Point) (*Forecast, *Error) {
 	if point == nil {
 		return nil, &Error{
 			Title:  "point is nil",
@@ -300,34 +545,14 @@ func GetForecastHourly(point *Point) (*Forecast, *Error) {
 		}
 	}
 
-	if body = fhCache.Get(point.Properties.ForecastHourly); len(body) == 0 {
-		// Get the hourly forecast.
-		body, expires, nwsErr = get(point.Properties.ForecastHourly)
-		if nwsErr != nil {
-			return nil, nwsErr
-		}
-		// Cache it.
-		fhCache.Set(point.Properties.ForecastHourly, body, expires)
-	}
-
-	// Unmarshal.
-	forecast := new(Forecast)
-	err := json.Unmarshal(body, forecast)
-	if err != nil {
-		return nil, &Error{
-			Title:  "forecast hourly json unmarshal failed",
-			Type:   "forecast-hourly-json-error",
-			Status: 500,
-			Detail: "forecast hourly json unmarshal failed",
-		}
+	forecast, stale, nwsErr := fetchDecoded(ctx, fhCache, fhValues,
+		point.Properties.ForecastHourly,
+		point.Properties.ForecastHourly, decodeForecastHourly)
+	if nwsErr != nil {
+		return nil, nwsErr
 	}
-	if len(forecast.Properties.Periods) == 0 {
-		return nil, &Error{
-			Title:  "forecast hourly has no periods",
-			Type:   "forecast-hourly-periods-empty",
-			Status: 500,
-			Detail: "forecast hourly has no periods",
-		}
+	if !stale.IsZero() {
+		forecast.StaleSince = stale
 	}
 
 	// Check for staleness.
@@ -341,8 +566,9 @@ func GetForecastHourly(point *Point) (*Forecast, *Error) {
 		}
 	}
 	if time.Since(genAt).Seconds() > 86400 {
-		fhCache.Set(point.Properties.ForecastHourly,
-			[]byte{}, time.Now()) // Invalidate cache.
+		// Invalidate cache.
+		fhCache.Delete(point.Properties.ForecastHourly)
+		fhValues.Delete(point.Properties.ForecastHourly)
 		return nil, &Error{
 			Title:  "forecast hourly is stale",
 			Type:   "forecast-hourly-stale-data",
@@ -355,11 +581,7 @@ func GetForecastHourly(point *Point) (*Forecast, *Error) {
 }
 
 // NWS forecast grid data endpoint.
-func GetForecastGridData(point *Point) (*ForecastGrid, *Error) {
-	var nwsErr *Error
-	var expires time.Time
-	var body []byte
-
+func GetForecastGridData(ctx context.Context, point *Point) (*ForecastGrid, *Error) {
 	if point == nil {
 		return nil, &Error{
 			Title:  "point is nil",
@@ -377,32 +599,84 @@ func GetForecastGridData(point *Point) (*ForecastGrid, *Error) {
 		}
 	}
 
-	if body = fgCache.Get(point.Properties.ForecastGridData); len(body) == 0 {
-		// Get the forecast grid data
-		body, expires, nwsErr = get(point.Properties.ForecastGridData)
-		if nwsErr != nil {
-			return nil, nwsErr
+	grid, stale, nwsErr := fetchDecoded(ctx, fgCache, fgValues,
+		point.Properties.ForecastGridData,
+		point.Properties.ForecastGridData, decodeForecastGrid)
+	if nwsErr != nil {
+		return nil, nwsErr
+	}
+	if !stale.IsZero() {
+		grid.StaleSince = stale
+	}
+	return grid, nil
+}
+
+// Expands the layer into an hourly time series.
+//
+// Hours that are not covered by any of the layer's values have the
+// zero value of T in the series.
+func (l GridLayer[T]) Hourly() (*HourlySeries[T], error) {
+	s := new(HourlySeries[T])
+	if len(l.Values) == 0 {
+		return s, nil
+	}
+
+	// Expand valid times.
+	hours := make([][]time.Time, len(l.Values))
+	var first, last time.Time
+	for i, v := range l.Values {
+		h, err := t.Hours(v.ValidTime)
+		if err != nil {
+			return nil, err
+		}
+		if first.IsZero() || h[0].Before(first) {
+			first = h[0]
+		}
+		if last.IsZero() || h[len(h)-1].After(last) {
+			last = h[len(h)-1]
 		}
-		// Cache it.
-		fgCache.Set(point.Properties.ForecastGridData, body, expires)
+		hours[i] = h
 	}
 
-	// Unmarshal.
-	grid := new(ForecastGrid)
-	err := json.Unmarshal(body, grid)
-	if err != nil {
-		return nil, &Error{
-			Title:  "forecast grid data json unmarshal failed",
-			Type:   "forecast-griddata-json-error",
-			Status: 500,
-			Detail: "forecast grid data json unmarshal failed",
+	// Fill the series.
+	s.Start = first
+	s.Values = make([]T, int(last.Sub(first)/time.Hour)+1)
+	for i, v := range l.Values {
+		for _, h := range hours[i] {
+			s.Values[int(h.Sub(first)/time.Hour)] = v.Value
 		}
 	}
-	return grid, nil
+	return s, nil
+}
+
+// Returns the value for the hour that contains time `at`. The second
+// return value is false when `at` is outside the series.
+func (s *HourlySeries[T]) At(at time.Time) (T, bool) {
+	var zero T
+	if s == nil || len(s.Values) == 0 || at.Before(s.Start) {
+		return zero, false
+	}
+	i := int(at.Sub(s.Start) / time.Hour)
+	if i >= len(s.Values) {
+		return zero, false
+	}
+	return s.Values[i], true
+}
+
+// Returns the values for the hours that overlap with the time period
+// from `start` to `end`.
+func (s *HourlySeries[T]) Between(start, end time.Time) []T {
+	values := []T{}
+	for h := start.Truncate(time.Hour); h.Before(end); h = h.Add(time.Hour) {
+		if v, ok := s.At(h); ok {
+			values = append(values, v)
+		}
+	}
+	return values
 }
 
 // NWS active alerts endpoint.
-func GetAlerts(lat, lng float32) (fc *FeatureCollection, err *Error) {
+func GetAlerts(ctx context.Context, lat, lng float32) (fc *FeatureCollection, err *Error) {
 	// Alerts endpoint.
 	u, uErr := baseUrl.Parse("/alerts/active")
 	if uErr != nil {
@@ -428,54 +702,340 @@ func GetAlerts(lat, lng float32) (fc *FeatureCollection, err *Error) {
 	u.RawQuery = q.Encode()
 
 	// Hit it.
-	var expires time.Time
-	var body []byte
-	if body = aCache.Get(ll); len(body) == 0 {
-		body, expires, err = get(u.String())
-		if err != nil {
-			return
+	fc, stale, err := fetchDecoded(ctx, aCache, aValues, ll, u.String(),
+		decodeAlerts)
+	if err != nil {
+		return
+	}
+	if !stale.IsZero() {
+		fc.StaleSince = stale
+	}
+	return
+}
+
+// NWS gridpoint observation stations endpoint.
+func GetStations(ctx context.Context, point *Point) (*Stations, *Error) {
+	if point == nil {
+		return nil, &Error{
+			Title:  "point is nil",
+			Type:   "stations-points-invalid",
+			Status: 500,
+			Detail: "point is nil",
 		}
-		// Cache it.
-		aCache.Set(ll, body, expires)
 	}
 
-	// Unmarshal.
-	fc = new(FeatureCollection)
-	jErr := json.Unmarshal(body, fc)
-	if jErr != nil {
-		err = &Error{
+	// Stations endpoint.
+	grid := fmt.Sprintf("%s/%d,%d", point.Properties.GridId,
+		point.Properties.GridX, point.Properties.GridY)
+	u, uErr := baseUrl.Parse("/gridpoints/" + grid + "/stations")
+	if uErr != nil {
+		return nil, &Error{
+			Title:  "stations url parsing failed",
+			Type:   "url-parse-error",
+			Status: 500,
+			Detail: uErr.Error(),
+		}
+	}
+
+	stations, _, nwsErr := fetchDecoded(ctx, sCache, sValues,
+		grid, u.String(), decodeStations)
+	if nwsErr != nil {
+		return nil, nwsErr
+	}
+	return stations, nil
+}
+
+// NWS latest station observation endpoint.
+func GetLatestObservation(ctx context.Context, station string) (*Observation, *Error) {
+	if len(station) == 0 {
+		return nil, &Error{
+			Title:  "station is empty",
+			Type:   "observation-station-invalid",
+			Status: 500,
+			Detail: "station is empty",
+		}
+	}
+
+	// Observation endpoint.
+	u, uErr := baseUrl.Parse("/stations/" + url.PathEscape(station) +
+		"/observations/latest")
+	if uErr != nil {
+		return nil, &Error{
+			Title:  "observation url parsing failed",
+			Type:   "url-parse-error",
+			Status: 500,
+			Detail: uErr.Error(),
+		}
+	}
+
+	observation, _, nwsErr := fetchDecoded(ctx, oCache, oValues,
+		station, u.String(), decodeObservation)
+	if nwsErr != nil {
+		return nil, nwsErr
+	}
+	return observation, nil
+}
+
+// NWS products endpoint for products of type `typ` (for instance,
+// AFD) issued by forecast office `wfo`.
+func GetProducts(ctx context.Context, typ, wfo string) (*Products, *Error) {
+	if len(typ) == 0 || len(wfo) == 0 {
+		return nil, &Error{
+			Title:  "product type or location is empty",
+			Type:   "products-invalid",
+			Status: 500,
+			Detail: "product type or location is empty",
+		}
+	}
+
+	// Products endpoint.
+	key := typ + "/" + wfo
+	u, uErr := baseUrl.Parse("/products/types/" + url.PathEscape(typ) +
+		"/locations/" + url.PathEscape(wfo))
+	if uErr != nil {
+		return nil, &Error{
+			Title:  "products url parsing failed",
+			Type:   "url-parse-error",
+			Status: 500,
+			Detail: uErr.Error(),
+		}
+	}
+
+	products, _, nwsErr := fetchDecoded(ctx, plCache, plValues,
+		key, u.String(), decodeProducts)
+	if nwsErr != nil {
+		return nil, nwsErr
+	}
+	return products, nil
+}
+
+// NWS product endpoint.
+func GetProduct(ctx context.Context, id string) (*Product, *Error) {
+	if len(id) == 0 {
+		return nil, &Error{
+			Title:  "product id is empty",
+			Type:   "product-id-invalid",
+			Status: 500,
+			Detail: "product id is empty",
+		}
+	}
+
+	// Product endpoint.
+	u, uErr := baseUrl.Parse("/products/" + url.PathEscape(id))
+	if uErr != nil {
+		return nil, &Error{
+			Title:  "product url parsing failed",
+			Type:   "url-parse-error",
+			Status: 500,
+			Detail: uErr.Error(),
+		}
+	}
+
+	product, _, nwsErr := fetchDecoded(ctx, prCache, prValues,
+		id, u.String(), decodeProduct)
+	if nwsErr != nil {
+		return nil, nwsErr
+	}
+	return product, nil
+}
+
+// Decodes a response from the NWS `/points` endpoint.
+func decodePoint(body []byte) (*Point, *Error) {
+	point := new(Point)
+	err := json.Unmarshal(body, point)
+	if err != nil {
+		return nil, &Error{
+			Title:  "unable json unmarshal",
+			Type:   "points-json-error",
+			Status: 500,
+			Detail: err.Error(),
+		}
+	}
+	if point.Properties.Forecast == "" {
+		return nil, &Error{
+			Title:  "forecast empty",
+			Type:   "points-forecast-error",
+			Status: 500,
+			Detail: "forecast is empty",
+		}
+	}
+	if point.Properties.ForecastHourly == "" {
+		return nil, &Error{
+			Title:  "forecast hourly empty",
+			Type:   "points-forecast-error",
+			Status: 500,
+			Detail: "forecast  hourly is empty",
+		}
+	}
+	return point, nil
+}
+
+// Decodes a response from the NWS forecast endpoint.
+func decodeForecast(body []byte) (*Forecast, *Error) {
+	forecast := new(Forecast)
+	err := json.Unmarshal(body, forecast)
+	if err != nil {
+		return nil, &Error{
+			Title:  "forecast json unmarshal failed",
+			Type:   "forecast-json-error",
+			Status: 500,
+			Detail: "forecast json unmarshal failed",
+		}
+	}
+	if len(forecast.Properties.Periods) == 0 {
+		return nil, &Error{
+			Title:  "forecast has no periods",
+			Type:   "forecast-periods-empty",
+			Status: 500,
+			Detail: "forecast has no periods",
+		}
+	}
+	return forecast, nil
+}
+
+// Decodes a response from the NWS forecast hourly endpoint.
+func decodeForecastHourly(body []byte) (*Forecast, *Error) {
+	forecast := new(Forecast)
+	err := json.Unmarshal(body, forecast)
+	if err != nil {
+		return nil, &Error{
+			Title:  "forecast hourly json unmarshal failed",
+			Type:   "forecast-hourly-json-error",
+			Status: 500,
+			Detail: "forecast hourly json unmarshal failed",
+		}
+	}
+	if len(forecast.Properties.Periods) == 0 {
+		return nil, &Error{
+			Title:  "forecast hourly has no periods",
+			Type:   "forecast-hourly-periods-empty",
+			Status: 500,
+			Detail: "forecast hourly has no periods",
+		}
+	}
+	return forecast, nil
+}
+
+// Decodes a response from the NWS forecast grid data endpoint.
+func decodeForecastGrid(body []byte) (*ForecastGrid, *Error) {
+	grid := new(ForecastGrid)
+	err := json.Unmarshal(body, grid)
+	if err != nil {
+		return nil, &Error{
+			Title:  "forecast grid data json unmarshal failed",
+			Type:   "forecast-griddata-json-error",
+			Status: 500,
+			Detail: "forecast grid data json unmarshal failed",
+		}
+	}
+	return grid, nil
+}
+
+// Decodes a response from the NWS active alerts endpoint.
+func decodeAlerts(body []byte) (*FeatureCollection, *Error) {
+	fc := new(FeatureCollection)
+	err := json.Unmarshal(body, fc)
+	if err != nil {
+		return nil, &Error{
 			Title:  "feature collection decode failed",
 			Type:   "json-decode-error",
 			Status: 500,
-			Detail: jErr.Error(),
+			Detail: err.Error(),
 		}
-		return
 	}
-	return
+	return fc, nil
+}
+
+// Decodes a response from the NWS gridpoint observation stations endpoint.
+func decodeStations(body []byte) (*Stations, *Error) {
+	stations := new(Stations)
+	err := json.Unmarshal(body, stations)
+	if err != nil {
+		return nil, &Error{
+			Title:  "stations json unmarshal failed",
+			Type:   "stations-json-error",
+			Status: 500,
+			Detail: err.Error(),
+		}
+	}
+	return stations, nil
+}
+
+// Decodes a response from the NWS latest station observation endpoint.
+func decodeObservation(body []byte) (*Observation, *Error) {
+	observation := new(Observation)
+	err := json.Unmarshal(body, observation)
+	if err != nil {
+		return nil, &Error{
+			Title:  "observation json unmarshal failed",
+			Type:   "observation-json-error",
+			Status: 500,
+			Detail: err.Error(),
+		}
+	}
+	return observation, nil
+}
+
+// Decodes a response from the NWS products endpoint.
+func decodeProducts(body []byte) (*Products, *Error) {
+	products := new(Products)
+	err := json.Unmarshal(body, products)
+	if err != nil {
+		return nil, &Error{
+			Title:  "products json unmarshal failed",
+			Type:   "products-json-error",
+			Status: 500,
+			Detail: err.Error(),
+		}
+	}
+	return products, nil
+}
+
+// Decodes a response from the NWS product endpoint.
+func decodeProduct(body []byte) (*Product, *Error) {
+	product := new(Product)
+	err := json.Unmarshal(body, product)
+	if err != nil {
+		return nil, &Error{
+			Title:  "product json unmarshal failed",
+			Type:   "product-json-error",
+			Status: 500,
+			Detail: err.Error(),
+		}
+	}
+	return product, nil
 }
 
 // HTTP GET a NWS endpoint.
-func get(url string) ([]byte, time.Time, *Error) {
+//
+// Failed requests are re-tried with an exponential back-off delay
+// until they succeed, run out of tries or `ctx` is done.
+func get(ctx context.Context, url string) ([]byte, time.Time, *Error) {
 	// Default response expiration time
 	expires := time.Now()
 
 	tries := 5
 	retryDelay := 100 * time.Millisecond
 	for {
-		resp, err := client.Get(url)
+		resp, err := client.Get(ctx, url)
 		if err != nil {
-			return nil, expires, &Error{
+			return nil, expires, contextError(ctx, url, &Error{
 				Title:  fmt.Sprintf("http get failed: %v", url),
 				Type:   "http-get",
 				Status: 500,
 				Detail: err.Error(),
-			}
+			})
 		}
 		if tries > 0 && resp.StatusCode != 200 {
 			tries -= 1
+			resp.Body.Close()
 
 			// Wait before re-try.
-			time.Sleep(retryDelay)
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+				return nil, expires, contextError(ctx, url, nil)
+			}
 
 			retryDelay *= 2 // Exponential back-off delay.
 			continue        // Re-try
@@ -483,13 +1043,14 @@ func get(url string) ([]byte, time.Time, *Error) {
 
 		// Parse response body.
 		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
-			return nil, expires, &Error{
+			return nil, expires, contextError(ctx, url, &Error{
 				Title:  fmt.Sprintf("parsing body: %v", url),
 				Type:   "response-body",
 				Status: 500,
 				Detail: err.Error(),
-			}
+			})
 		}
 
 		// Check if the request failed.
@@ -530,3 +1091,25 @@ func get(url string) ([]byte, time.Time, *Error) {
 		return body, expires, nil
 	}
 }
+
+// Returns an error for a request to `url` that was cut short because
+// `ctx` is done. If `ctx` is not done, `err` is returned as is.
+func contextError(ctx context.Context, url string, err *Error) *Error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return &Error{
+			Title:  fmt.Sprintf("timed out: %v", url),
+			Type:   "request-timeout",
+			Status: 504,
+			Detail: ctx.Err().Error(),
+		}
+	case context.Canceled:
+		return &Error{
+			Title:  fmt.Sprintf("canceled: %v", url),
+			Type:   "request-canceled",
+			Status: 500,
+			Detail: ctx.Err().Error(),
+		}
+	}
+	return err
+}
@@ -4,18 +4,25 @@
 package nws
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"ricketyspace.net/peach/cache"
 )
 
 func TestPoints(t *testing.T) {
 	// Test valid lat,lng.
-	np, err := Points(41.115, -83.177)
+	np, err := Points(context.Background(), 41.115, -83.177)
 	if err != nil {
 		t.Errorf("points: %v", err)
 		return
@@ -44,7 +51,7 @@ func TestPoints(t *testing.T) {
 	}
 
 	// Test invalid lat,lng
-	np, err = Points(115.0, -83.177)
+	np, err = Points(context.Background(), 115.0, -83.177)
 	if err == nil {
 		t.Errorf("points: %v", np)
 	}
@@ -52,14 +59,14 @@ func TestPoints(t *testing.T) {
 
 func TestGetForecast(t *testing.T) {
 	// Get point.
-	np, nwsErr := Points(41.115, -83.177)
+	np, nwsErr := Points(context.Background(), 41.115, -83.177)
 	if nwsErr != nil {
 		t.Errorf("error: %v", nwsErr)
 		return
 	}
 
 	// Get forecast.
-	fc, nwsErr := GetForecast(np)
+	fc, nwsErr := GetForecast(context.Background(), np)
 	if nwsErr != nil {
 		t.Errorf("error: %v", nwsErr)
 		return
@@ -106,14 +113,14 @@ func TestGetForecast(t *testing.T) {
 
 func TestGetForecastHourly(t *testing.T) {
 	// Get point.
-	np, nwsErr := Points(41.115, -83.177)
+	np, nwsErr := Points(context.Background(), 41.115, -83.177)
 	if nwsErr != nil {
 		t.Errorf("error: %v", nwsErr)
 		return
 	}
 
 	// Get forecast hourly.
-	fc, nwsErr := GetForecastHourly(np)
+	fc, nwsErr := GetForecastHourly(context.Background(), np)
 	if nwsErr != nil {
 		t.Errorf("error: %v", nwsErr)
 		return
@@ -153,14 +160,14 @@ func TestGetForecastHourly(t *testing.T) {
 
 func TestGetForecastGridData(t *testing.T) {
 	// Get point.
-	np, nwsErr := Points(41.115, -83.177)
+	np, nwsErr := Points(context.Background(), 41.115, -83.177)
 	if nwsErr != nil {
 		t.Errorf("error: %v", nwsErr)
 		return
 	}
 
 	// Get forecast grid data
-	grid, nwsErr := GetForecastGridData(np)
+	grid, nwsErr := GetForecastGridData(context.Background(), np)
 	if nwsErr != nil {
 		t.Errorf("error: %v", nwsErr)
 		return
@@ -178,7 +185,7 @@ func TestGetForecastGridData(t *testing.T) {
 				value.ValidTime)
 			return
 		}
-		if value.Value < 1 {
+		if value.Value == nil || *value.Value < 1 {
 			t.Errorf("humidity: %d: value invalid: %v", i,
 				value.Value)
 			return
@@ -186,6 +193,178 @@ func TestGetForecastGridData(t *testing.T) {
 	}
 }
 
+func TestForecastGridDataLayers(t *testing.T) {
+	grid := `{"properties":{"updateTime":"2022-08-07T01:35:08+00:00","validTimes":"2022-08-06T19:00:00+00:00/P7DT6H","elevation":{"unitCode":"wmoUnit:m","value":231.9528},"forecastOffice":"https://api.weather.gov/offices/CLE","gridId":"CLE","gridX":33,"gridY":42,"temperature":{"uom":"wmoUnit:degC","values":[{"validTime":"2022-08-07T02:00:00+00:00/PT1H","value":23.88888888888889},{"validTime":"2022-08-07T03:00:00+00:00/PT2H","value":22.22222222222222}]},"relativeHumidity":{"uom":"wmoUnit:percent","values":[{"validTime":"2022-08-07T02:00:00+00:00/PT3H","value":84}]},"windGust":{"uom":"wmoUnit:km_h-1","values":[{"validTime":"2022-08-07T02:00:00+00:00/PT3H","value":null}]},"quantitativePrecipitation":{"uom":"wmoUnit:mm","values":[{"validTime":"2022-08-07T00:00:00+00:00/PT6H","value":0.508}]},"weather":{"values":[{"validTime":"2022-08-07T02:00:00+00:00/PT4H","value":[{"coverage":"chance","weather":"thunderstorms","intensity":null,"visibility":{"unitCode":"wmoUnit:km","value":null},"attributes":[]}]}]},"hazards":{"values":[{"validTime":"2022-08-07T02:00:00+00:00/PT12H","value":[{"phenomenon":"HT","significance":"Y","event_number":null}]}]}}}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("expires",
+			time.Now().Add(time.Second*60).Format(time.RFC1123))
+		fmt.Fprint(w, grid)
+	}))
+	defer ts.Close()
+
+	np := new(Point)
+	np.Properties.ForecastGridData = ts.URL
+	g, nwsErr := GetForecastGridData(context.Background(), np)
+	if nwsErr != nil {
+		t.Errorf("error: %v", nwsErr)
+		return
+	}
+	p := g.Properties
+	if p.GridId != "CLE" || p.GridX != 33 || p.GridY != 42 {
+		t.Errorf("grid: %v %v,%v", p.GridId, p.GridX, p.GridY)
+		return
+	}
+	if p.Elevation.UnitCode != "wmoUnit:m" || *p.Elevation.Value != 231.9528 {
+		t.Errorf("elevation: %v", p.Elevation)
+		return
+	}
+	if p.Temperature.Uom != "wmoUnit:degC" || len(p.Temperature.Values) != 2 {
+		t.Errorf("temperature: %v", p.Temperature)
+		return
+	}
+	if p.Temperature.Values[1].ValidTime != "2022-08-07T03:00:00+00:00/PT2H" {
+		t.Errorf("temperature: valid time: %v",
+			p.Temperature.Values[1].ValidTime)
+		return
+	}
+	if *p.Temperature.Values[1].Value != 22.22222222222222 {
+		t.Errorf("temperature: value: %v", *p.Temperature.Values[1].Value)
+		return
+	}
+	if *p.RelativeHumidity.Values[0].Value != 84 {
+		t.Errorf("humidity: value: %v", *p.RelativeHumidity.Values[0].Value)
+		return
+	}
+	if p.WindGust.Uom != "wmoUnit:km_h-1" || p.WindGust.Values[0].Value != nil {
+		t.Errorf("wind gust: %v", p.WindGust)
+		return
+	}
+	if *p.QuantitativePrecipitation.Values[0].Value != 0.508 {
+		t.Errorf("precipitation: %v", p.QuantitativePrecipitation)
+		return
+	}
+	if len(p.Weather.Values[0].Value) != 1 {
+		t.Errorf("weather: %v", p.Weather)
+		return
+	}
+	wx := p.Weather.Values[0].Value[0]
+	if wx.Coverage != "chance" || wx.Weather != "thunderstorms" ||
+		wx.Visibility.Value != nil {
+		t.Errorf("weather: %v", wx)
+		return
+	}
+	hz := p.Hazards.Values[0].Value[0]
+	if hz.Phenomenon != "HT" || hz.Significance != "Y" {
+		t.Errorf("hazards: %v", hz)
+		return
+	}
+	if len(p.SnowfallAmount.Values) != 0 {
+		t.Errorf("snowfall: %v", p.SnowfallAmount)
+		return
+	}
+}
+
+func TestForecastPeriods(t *testing.T) {
+	forecast := `{"properties":{"generatedAt":"2022-08-07T14:00:00+00:00","periods":[{"number":1,"name":"Today","startTime":"2022-08-07T10:00:00-04:00","endTime":"2022-08-07T18:00:00-04:00","isDaytime":true,"temperature":86,"temperatureUnit":"F","probabilityOfPrecipitation":{"unitCode":"wmoUnit:percent","value":40},"windSpeed":"5 to 10 mph","windDirection":"SW","icon":"https://api.weather.gov/icons/land/day/tsra_hi,40?size=medium","shortForecast":"Chance Showers And Thunderstorms","detailedForecast":"A chance of showers and thunderstorms."},{"number":2,"name":"Tonight","startTime":"2022-08-07T18:00:00-04:00","endTime":"2022-08-08T06:00:00-04:00","isDaytime":false,"temperature":68,"temperatureUnit":"F","probabilityOfPrecipitation":{"unitCode":"wmoUnit:percent","value":null},"windSpeed":"5 mph","windDirection":"W","icon":"https://api.weather.gov/icons/land/night/few?size=medium","shortForecast":"Mostly Clear","detailedForecast":"Mostly clear."}]}}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("expires",
+			time.Now().Add(time.Second*60).Format(time.RFC1123))
+		fmt.Fprint(w, forecast)
+	}))
+	defer ts.Close()
+
+	np := new(Point)
+	np.Properties.Forecast = ts.URL
+	fc, nwsErr := GetForecast(context.Background(), np)
+	if nwsErr != nil {
+		t.Errorf("error: %v", nwsErr)
+		return
+	}
+	if len(fc.Properties.Periods) != 2 {
+		t.Errorf("periods: %v", fc.Properties.Periods)
+		return
+	}
+	p := fc.Properties.Periods[0]
+	if !p.IsDayTime || p.Icon != "https://api.weather.gov/icons/land/day/tsra_hi,40?size=medium" ||
+		p.ShortForecast != "Chance Showers And Thunderstorms" {
+		t.Errorf("period: %v", p)
+		return
+	}
+	pop := p.ProbabilityOfPrecipitation
+	if pop.UnitCode != "wmoUnit:percent" || pop.Value == nil || *pop.Value != 40 {
+		t.Errorf("period: pop: %v", pop)
+		return
+	}
+	p = fc.Properties.Periods[1]
+	if p.IsDayTime || p.ProbabilityOfPrecipitation.Value != nil {
+		t.Errorf("period: %v", p)
+		return
+	}
+}
+
+func TestGridLayerHourly(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	l := GridLayer[*float64]{
+		Uom: "wmoUnit:percent",
+		Values: []GridValue[*float64]{
+			{ValidTime: "2022-08-07T02:00:00+00:00/PT2H", Value: f(84)},
+			{ValidTime: "2022-08-07T04:00:00+00:00/PT1H", Value: f(80)},
+			{ValidTime: "2022-08-07T07:00:00+00:00/PT1H", Value: f(75)},
+		},
+	}
+	s, err := l.Hourly()
+	if err != nil {
+		t.Errorf("hourly: %v", err)
+		return
+	}
+	if s.Start.Format(time.RFC3339) != "2022-08-07T02:00:00Z" {
+		t.Errorf("hourly: start: %v", s.Start)
+		return
+	}
+	if len(s.Values) != 6 {
+		t.Errorf("hourly: values: %v", s.Values)
+		return
+	}
+
+	// Look up values.
+	at := func(ts string) (*float64, bool) {
+		tm, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			t.Fatalf("hourly: %v", err)
+		}
+		return s.At(tm)
+	}
+	if v, ok := at("2022-08-07T03:59:00Z"); !ok || *v != 84 {
+		t.Errorf("hourly: at 03:59: %v %v", v, ok)
+		return
+	}
+	if v, ok := at("2022-08-07T00:30:00-04:00"); !ok || *v != 80 {
+		t.Errorf("hourly: at 04:30Z: %v %v", v, ok)
+		return
+	}
+	if v, ok := at("2022-08-07T05:00:00Z"); !ok || v != nil {
+		t.Errorf("hourly: at 05:00 (gap): %v %v", v, ok)
+		return
+	}
+	if _, ok := at("2022-08-07T08:00:00Z"); ok {
+		t.Errorf("hourly: at 08:00 is in the series")
+		return
+	}
+	if _, ok := at("2022-08-07T01:00:00Z"); ok {
+		t.Errorf("hourly: at 01:00 is in the series")
+		return
+	}
+
+	// Values between.
+	t1, _ := time.Parse(time.RFC3339, "2022-08-07T03:00:00Z")
+	t2, _ := time.Parse(time.RFC3339, "2022-08-07T06:00:00Z")
+	vs := s.Between(t1, t2)
+	if len(vs) != 3 || *vs[0] != 84 || *vs[1] != 80 || vs[2] != nil {
+		t.Errorf("hourly: between: %v", vs)
+		return
+	}
+}
+
 func TestNWSGetWrapper(t *testing.T) {
 	// Initialize test NWS server.
 	fails := 0
@@ -207,7 +386,7 @@ func TestNWSGetWrapper(t *testing.T) {
 
 	// Test 1 - Server fails 5 times.
 	fails = 5
-	_, _, err := get(ts.URL)
+	_, _, err := get(context.Background(), ts.URL)
 	if err != nil {
 		t.Errorf("get failed: %v", err)
 		return
@@ -215,7 +394,7 @@ func TestNWSGetWrapper(t *testing.T) {
 
 	// Test 2 - Server fails 6 times.
 	fails = 6
-	respBody, _, err := get(ts.URL)
+	respBody, _, err := get(context.Background(), ts.URL)
 	if err == nil {
 		t.Errorf("get did not fail: %s", respBody)
 		return
@@ -242,7 +421,7 @@ func TestNWSGetWrapper(t *testing.T) {
 
 	// Test 3 - Server fails 1 time.
 	fails = 1
-	respBody, expires, err := get(ts.URL)
+	respBody, expires, err := get(context.Background(), ts.URL)
 	if err != nil {
 		t.Errorf("get failed: %v", err)
 		return
@@ -279,6 +458,42 @@ func TestNWSGetWrapper(t *testing.T) {
 	}
 }
 
+func TestNWSGetWrapperContext(t *testing.T) {
+	// Initialize test NWS server that always fails.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"type":"urn:noaa:nws:api:UnexpectedProblem","title":"Unexpected Problem","status":500,"detail":"An unexpected problem has occurred."}`, 500)
+	}))
+	defer ts.Close()
+
+	// Deadline is hit while waiting to re-try.
+	ctx, cancel := context.WithTimeout(context.Background(),
+		250*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := get(ctx, ts.URL)
+	if err == nil {
+		t.Errorf("get did not fail")
+		return
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("get did not stop at the deadline: %v", time.Since(start))
+		return
+	}
+	if err.Status != 504 || err.Type != "request-timeout" {
+		t.Errorf("err: %v", err)
+		return
+	}
+
+	// Canceled before the request is made.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, _, err = get(ctx, ts.URL)
+	if err == nil || err.Type != "request-canceled" {
+		t.Errorf("err: %v", err)
+		return
+	}
+}
+
 func TestAlerts(t *testing.T) {
 	// Initialize test NWS server.
 	fail := false
@@ -301,7 +516,7 @@ func TestAlerts(t *testing.T) {
 
 	// Hit it.
 	fail = true
-	_, nwsErr := GetAlerts(33.2938, -83.9674)
+	_, nwsErr := GetAlerts(context.Background(), 33.2938, -83.9674)
 	if nwsErr == nil {
 		t.Errorf("alerts: expected it to fail")
 		return
@@ -325,7 +540,7 @@ func TestAlerts(t *testing.T) {
 
 	// Hit it again.
 	fail = false
-	fc, nwsErr := GetAlerts(33.2938, -83.9674)
+	fc, nwsErr := GetAlerts(context.Background(), 33.2938, -83.9674)
 	if nwsErr != nil {
 		t.Errorf("alerts: %v", nwsErr)
 		return
@@ -374,3 +589,531 @@ func TestAlerts(t *testing.T) {
 		return
 	}
 }
+
+func TestLatestObservation(t *testing.T) {
+	stations := `{"type":"FeatureCollection","features":[{"id":"https://api.weather.gov/stations/KTDZ","type":"Feature","properties":{"@id":"https://api.weather.gov/stations/KTDZ","@type":"wx:ObservationStation","stationIdentifier":"KTDZ","name":"Toledo Executive Airport","timeZone":"America/New_York"}},{"id":"https://api.weather.gov/stations/KFDY","type":"Feature","properties":{"stationIdentifier":"KFDY","name":"Findlay Airport"}}],"observationStations":["https://api.weather.gov/stations/KTDZ","https://api.weather.gov/stations/KFDY"]}`
+	observation := `{"id":"https://api.weather.gov/stations/KTDZ/observations/2022-08-07T14:53:00+00:00","type":"Feature","properties":{"station":"https://api.weather.gov/stations/KTDZ","timestamp":"2022-08-07T14:53:00+00:00","textDescription":"Mostly Cloudy","temperature":{"unitCode":"wmoUnit:degC","value":24.4,"qualityControl":"V"},"dewpoint":{"unitCode":"wmoUnit:degC","value":20,"qualityControl":"V"},"windDirection":{"unitCode":"wmoUnit:degree_(angle)","value":230,"qualityControl":"V"},"windSpeed":{"unitCode":"wmoUnit:km_h-1","value":14.76,"qualityControl":"V"},"windGust":{"unitCode":"wmoUnit:km_h-1","value":null,"qualityControl":"Z"},"barometricPressure":{"unitCode":"wmoUnit:Pa","value":101320,"qualityControl":"V"},"visibility":{"unitCode":"wmoUnit:m","value":16090,"qualityControl":"C"},"relativeHumidity":{"unitCode":"wmoUnit:percent","value":76.5,"qualityControl":"V"}}}`
+	paths := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+
+		// Add expires header.
+		w.Header().Set("expires",
+			time.Now().Add(time.Second*60).Format(time.RFC1123))
+
+		switch r.URL.Path {
+		case "/gridpoints/CLE/33,42/stations":
+			fmt.Fprint(w, stations)
+		case "/stations/KTDZ/observations/latest":
+			fmt.Fprint(w, observation)
+		default:
+			http.Error(w, `{"type":"urn:noaa:nws:api:NotFound","title":"Not Found","status":404,"detail":"not found"}`, 404)
+		}
+	}))
+	defer ts.Close()
+	baseUrl, _ = url.Parse(ts.URL)
+
+	np := new(Point)
+	np.Properties.GridId = "CLE"
+	np.Properties.GridX = 33
+	np.Properties.GridY = 42
+	s, nwsErr := GetStations(context.Background(), np)
+	if nwsErr != nil {
+		t.Errorf("stations: %v", nwsErr)
+		return
+	}
+	if len(s.Features) != 2 {
+		t.Errorf("stations: features: %v", s.Features)
+		return
+	}
+	if s.Features[0].Properties.StationIdentifier != "KTDZ" {
+		t.Errorf("stations: nearest: %v", s.Features[0].Properties)
+		return
+	}
+
+	o, nwsErr := GetLatestObservation(context.Background(), "KTDZ")
+	if nwsErr != nil {
+		t.Errorf("observation: %v", nwsErr)
+		return
+	}
+	p := o.Properties
+	if p.Timestamp != "2022-08-07T14:53:00+00:00" {
+		t.Errorf("observation: timestamp: %v", p.Timestamp)
+		return
+	}
+	if p.TextDescription != "Mostly Cloudy" {
+		t.Errorf("observation: description: %v", p.TextDescription)
+		return
+	}
+	if p.Temperature.UnitCode != "wmoUnit:degC" || *p.Temperature.Value != 24.4 {
+		t.Errorf("observation: temperature: %v", p.Temperature)
+		return
+	}
+	if *p.WindSpeed.Value != 14.76 || p.WindGust.Value != nil {
+		t.Errorf("observation: wind: %v %v", p.WindSpeed, p.WindGust)
+		return
+	}
+	if *p.BarometricPressure.Value != 101320 || *p.Visibility.Value != 16090 {
+		t.Errorf("observation: pressure, visibility: %v %v",
+			p.BarometricPressure, p.Visibility)
+		return
+	}
+
+	// Cached; must not hit the server again.
+	n := len(paths)
+	_, nwsErr = GetStations(context.Background(), np)
+	if nwsErr != nil {
+		t.Errorf("stations: %v", nwsErr)
+		return
+	}
+	_, nwsErr = GetLatestObservation(context.Background(), "KTDZ")
+	if nwsErr != nil {
+		t.Errorf("observation: %v", nwsErr)
+		return
+	}
+	if len(paths) != n {
+		t.Errorf("not cached: %v", paths)
+		return
+	}
+
+	// Unknown station.
+	_, nwsErr = GetLatestObservation(context.Background(), "KXXX")
+	if nwsErr == nil || nwsErr.Status != 404 {
+		t.Errorf("observation: unknown station: %v", nwsErr)
+		return
+	}
+}
+
+func TestProducts(t *testing.T) {
+	products := `{"@context":{"@version":"1.1"},"@graph":[{"@id":"https://api.weather.gov/products/3b7ac6a4-4b4e-4d6b-9a5f-3a1f0c1b2d3e","id":"3b7ac6a4-4b4e-4d6b-9a5f-3a1f0c1b2d3e","wmoCollectiveId":"FXUS61","issuingOffice":"KCLE","issuanceTime":"2022-08-07T10:41:00+00:00","productCode":"AFD","productName":"Area Forecast Discussion"},{"@id":"https://api.weather.gov/products/0a1b2c3d","id":"0a1b2c3d","wmoCollectiveId":"FXUS61","issuingOffice":"KCLE","issuanceTime":"2022-08-07T07:30:00+00:00","productCode":"AFD","productName":"Area Forecast Discussion"}]}`
+	product := `{"@id":"https://api.weather.gov/products/3b7ac6a4-4b4e-4d6b-9a5f-3a1f0c1b2d3e","id":"3b7ac6a4-4b4e-4d6b-9a5f-3a1f0c1b2d3e","wmoCollectiveId":"FXUS61","issuingOffice":"KCLE","issuanceTime":"2022-08-07T10:41:00+00:00","productCode":"AFD","productName":"Area Forecast Discussion","productText":"\n000\nFXUS61 KCLE 071041\nAFDCLE\n\n.SYNOPSIS...\nA warm front will lift north.\n\n&&\n\n$$\n"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Add expires header.
+		w.Header().Set("expires",
+			time.Now().Add(time.Second*60).Format(time.RFC1123))
+
+		switch r.URL.Path {
+		case "/products/types/AFD/locations/CLE":
+			fmt.Fprint(w, products)
+		case "/products/3b7ac6a4-4b4e-4d6b-9a5f-3a1f0c1b2d3e":
+			fmt.Fprint(w, product)
+		default:
+			http.Error(w, `{"type":"urn:noaa:nws:api:NotFound","title":"Not Found","status":404,"detail":"not found"}`, 404)
+		}
+	}))
+	defer ts.Close()
+	baseUrl, _ = url.Parse(ts.URL)
+
+	ps, nwsErr := GetProducts(context.Background(), "AFD", "CLE")
+	if nwsErr != nil {
+		t.Errorf("products: %v", nwsErr)
+		return
+	}
+	if len(ps.Graph) != 2 {
+		t.Errorf("products: %v", ps.Graph)
+		return
+	}
+	if ps.Graph[0].Id != "3b7ac6a4-4b4e-4d6b-9a5f-3a1f0c1b2d3e" {
+		t.Errorf("products: latest: %v", ps.Graph[0])
+		return
+	}
+	if ps.Graph[0].IssuanceTime != "2022-08-07T10:41:00+00:00" {
+		t.Errorf("products: issuance time: %v", ps.Graph[0].IssuanceTime)
+		return
+	}
+
+	p, nwsErr := GetProduct(context.Background(), ps.Graph[0].Id)
+	if nwsErr != nil {
+		t.Errorf("product: %v", nwsErr)
+		return
+	}
+	if p.ProductCode != "AFD" || p.IssuingOffice != "KCLE" {
+		t.Errorf("product: %v %v", p.ProductCode, p.IssuingOffice)
+		return
+	}
+	if p.ProductText != "\n000\nFXUS61 KCLE 071041\nAFDCLE\n\n.SYNOPSIS...\nA warm front will lift north.\n\n&&\n\n$$\n" {
+		t.Errorf("product: text: %q", p.ProductText)
+		return
+	}
+}
+
+func TestForecastBundle(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Add expires header.
+		w.Header().Set("expires",
+			time.Now().Add(time.Second*60).Format(time.RFC1123))
+
+		genAt := time.Now().UTC().Format(time.RFC3339)
+		switch r.URL.Path {
+		case "/points/41.4990,-81.6940":
+			fmt.Fprintf(w, `{"properties":{"gridId":"CLE","gridX":82,"gridY":64,"forecast":"%[1]s/gridpoints/CLE/82,64/forecast","forecastHourly":"%[1]s/gridpoints/CLE/82,64/forecast/hourly","forecastGridData":"%[1]s/gridpoints/CLE/82,64","relativeLocation":{"properties":{"city":"Tiffin","state":"OH"}}}}`, ts.URL)
+		case "/gridpoints/CLE/82,64/forecast":
+			fmt.Fprintf(w, `{"properties":{"generatedAt":"%s","periods":[{"number":1,"name":"Today","startTime":"2022-08-07T06:00:00-04:00","endTime":"2022-08-07T18:00:00-04:00","temperature":86,"temperatureUnit":"F"}]}}`, genAt)
+		case "/gridpoints/CLE/82,64/forecast/hourly":
+			fmt.Fprintf(w, `{"properties":{"generatedAt":"%s","periods":[{"number":1,"startTime":"2022-08-07T06:00:00-04:00","endTime":"2022-08-07T07:00:00-04:00","temperature":72,"temperatureUnit":"F"}]}}`, genAt)
+		default: // Grid data, alerts and stations fail.
+			http.Error(w, `{"type":"urn:noaa:nws:api:UnexpectedProblem","title":"Unexpected Problem","status":500,"detail":"An unexpected problem has occurred."}`, 500)
+		}
+	}))
+	defer ts.Close()
+	baseUrl, _ = url.Parse(ts.URL)
+
+	b, nwsErr := GetForecastBundle(context.Background(), 41.499, -81.694)
+	if nwsErr != nil {
+		t.Errorf("bundle: %v", nwsErr)
+		return
+	}
+	if b.Point == nil || b.Forecast == nil || b.ForecastHourly == nil {
+		t.Errorf("bundle: essential component missing: %v", b)
+		return
+	}
+	if b.ForecastErr != nil || b.ForecastHourlyErr != nil {
+		t.Errorf("bundle: %v %v", b.ForecastErr, b.ForecastHourlyErr)
+		return
+	}
+	if b.Forecast.Properties.Periods[0].Temperature != 86 {
+		t.Errorf("bundle: forecast: %v", b.Forecast.Properties.Periods)
+		return
+	}
+	if b.ForecastHourly.Properties.Periods[0].Temperature != 72 {
+		t.Errorf("bundle: hourly: %v", b.ForecastHourly.Properties.Periods)
+		return
+	}
+	if b.ForecastGrid != nil || b.ForecastGridErr == nil ||
+		b.ForecastGridErr.Status != 500 {
+		t.Errorf("bundle: grid: %v %v", b.ForecastGrid, b.ForecastGridErr)
+		return
+	}
+	if b.Alerts != nil || b.AlertsErr == nil {
+		t.Errorf("bundle: alerts: %v %v", b.Alerts, b.AlertsErr)
+		return
+	}
+	if b.Observation != nil || b.ObservationErr == nil {
+		t.Errorf("bundle: observation: %v %v", b.Observation, b.ObservationErr)
+		return
+	}
+}
+
+func TestFetchCoalesces(t *testing.T) {
+	product := `{"id":"f1e2d3","productCode":"AFD","productText":"AREA FORECAST DISCUSSION"}`
+	hits := make(chan int, 20)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits <- 1
+		time.Sleep(100 * time.Millisecond) // Keep the request in flight.
+
+		// Add expires header.
+		w.Header().Set("expires",
+			time.Now().Add(time.Second*60).Format(time.RFC1123))
+		fmt.Fprint(w, product)
+	}))
+	defer ts.Close()
+	baseUrl, _ = url.Parse(ts.URL)
+
+	// Ten concurrent misses for the same product.
+	wg := sync.WaitGroup{}
+	errs := make(chan *Error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, nwsErr := GetProduct(context.Background(), "f1e2d3")
+			if nwsErr == nil && p.ProductCode != "AFD" {
+				nwsErr = &Error{Detail: "product code: " + p.ProductCode}
+			}
+			errs <- nwsErr
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for nwsErr := range errs {
+		if nwsErr != nil {
+			t.Errorf("product: %v", nwsErr)
+			return
+		}
+	}
+	if len(hits) != 1 {
+		t.Errorf("product: %d requests to weather.gov, not 1", len(hits))
+		return
+	}
+
+	// A caller that gives up does not cut short the others.
+	ctx, cancel := context.WithTimeout(context.Background(),
+		20*time.Millisecond)
+	defer cancel()
+	done := make(chan *Error)
+	go func() {
+		_, nwsErr := GetProduct(ctx, "a9b8c7")
+		done <- nwsErr
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p, nwsErr := GetProduct(context.Background(), "a9b8c7")
+	if nwsErr != nil {
+		t.Errorf("product: %v", nwsErr)
+		return
+	}
+	if p.ProductCode != "AFD" {
+		t.Errorf("product: code: %v", p.ProductCode)
+		return
+	}
+	if nwsErr := <-done; nwsErr == nil || nwsErr.Type != "request-timeout" {
+		t.Errorf("product: timed out caller: %v", nwsErr)
+		return
+	}
+}
+
+func TestFetchStale(t *testing.T) {
+	forecast := `{"properties":{"generatedAt":"2022-08-07T14:00:00+00:00","periods":[{"number":1,"name":"Today","temperature":%d,"temperatureUnit":"F"}]}}`
+	refreshed := make(chan int, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("expires",
+			time.Now().Add(time.Second*60).Format(time.RFC1123))
+		fmt.Fprintf(w, forecast, 84)
+		refreshed <- 1
+	}))
+	defer ts.Close()
+
+	// Keep expired items for an hour.
+	UseStores(func(string) (cache.Store, error) {
+		c := cache.NewCache()
+		c.SetGrace(time.Hour)
+		return c, nil
+	})
+	defer UseStores(func(string) (cache.Store, error) {
+		return cache.NewCache(), nil
+	})
+
+	np := new(Point)
+	np.Properties.Forecast = ts.URL + "/gridpoints/CLE/33,42/forecast"
+	expired := time.Now().Add(-time.Minute).Truncate(time.Second)
+	fCache.Set(np.Properties.Forecast, []byte(fmt.Sprintf(forecast, 72)),
+		expired)
+
+	// The stale copy is served right away.
+	f, nwsErr := GetForecast(context.Background(), np)
+	if nwsErr != nil {
+		t.Errorf("forecast: %v", nwsErr)
+		return
+	}
+	if f.Properties.Periods[0].Temperature != 72 {
+		t.Errorf("forecast: not the stale copy: %v", f.Properties.Periods[0])
+		return
+	}
+	if !f.StaleSince.Equal(expired) {
+		t.Errorf("forecast: stale since: %v != %v", f.StaleSince, expired)
+		return
+	}
+
+	// And refreshed in the background.
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Errorf("forecast: not refreshed")
+		return
+	}
+	for i := 0; i < 50; i++ {
+		f, nwsErr = GetForecast(context.Background(), np)
+		if nwsErr != nil {
+			t.Errorf("forecast: %v", nwsErr)
+			return
+		}
+		if f.StaleSince.IsZero() {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if f.Properties.Periods[0].Temperature != 84 || !f.StaleSince.IsZero() {
+		t.Errorf("forecast: not the refreshed copy: %v %v",
+			f.Properties.Periods[0], f.StaleSince)
+		return
+	}
+}
+
+func TestUseStores(t *testing.T) {
+	alerts := `{"type":"FeatureCollection","features":[]}`
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits += 1
+		w.Header().Set("expires",
+			time.Now().Add(time.Second*60).Format(time.RFC1123))
+		fmt.Fprint(w, alerts)
+	}))
+	defer ts.Close()
+	baseUrl, _ = url.Parse(ts.URL)
+
+	// Use file stores.
+	dir := t.TempDir()
+	newFileStore := func(name string) (cache.Store, error) {
+		return cache.NewFileStore(filepath.Join(dir, name))
+	}
+	err := UseStores(newFileStore)
+	if err != nil {
+		t.Errorf("use stores: %v", err)
+		return
+	}
+	defer UseStores(func(string) (cache.Store, error) {
+		return cache.NewCache(), nil
+	})
+
+	_, nwsErr := GetAlerts(context.Background(), 40.7128, -74.006)
+	if nwsErr != nil {
+		t.Errorf("alerts: %v", nwsErr)
+		return
+	}
+
+	// Stores are re-opened as if peach restarted; the alerts must
+	// come from the file store.
+	err = UseStores(newFileStore)
+	if err != nil {
+		t.Errorf("use stores: %v", err)
+		return
+	}
+	_, nwsErr = GetAlerts(context.Background(), 40.7128, -74.006)
+	if nwsErr != nil {
+		t.Errorf("alerts: %v", nwsErr)
+		return
+	}
+	if hits != 1 {
+		t.Errorf("alerts: not cached in file store: hits: %d", hits)
+		return
+	}
+
+	// Failing store.
+	err = UseStores(func(name string) (cache.Store, error) {
+		return nil, fmt.Errorf("nope")
+	})
+	if err == nil || err.Error() != "store: points: nope" {
+		t.Errorf("use stores: %v", err)
+		return
+	}
+}
+
+func TestLimitDecoded(t *testing.T) {
+	product := `{"id":"%s","productCode":"AFD","issuingOffice":"KCLE","productText":"A warm front will lift north."}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("expires",
+			time.Now().Add(time.Second*60).Format(time.RFC1123))
+		fmt.Fprintf(w, product, path.Base(r.URL.Path))
+	}))
+	defer ts.Close()
+	baseUrl, _ = url.Parse(ts.URL)
+
+	// Room for one product only.
+	size := len("aaaa") + len(fmt.Sprintf(product, "aaaa"))
+	LimitDecoded(size + 10)
+	defer LimitDecoded(0)
+
+	for _, id := range []string{"aaaa", "bbbb"} {
+		p, nwsErr := GetProduct(context.Background(), id)
+		if nwsErr != nil {
+			t.Errorf("product: %s: %v", id, nwsErr)
+			return
+		}
+		if p.Id != id {
+			t.Errorf("product: %s: id: %v", id, p.Id)
+			return
+		}
+	}
+	stats := prValues.Stats()
+	if stats.Entries != 1 || stats.Bytes != size || stats.Evictions != 1 {
+		t.Errorf("decoded: stats: %+v", stats)
+		return
+	}
+	if d, _ := prValues.GetStale("bbbb"); d.value == nil || d.value.Id != "bbbb" {
+		t.Errorf("decoded: latest product not kept: %v", d)
+		return
+	}
+}
+
+// Runs the requests to weather.gov for a page render that is served
+// from a warm cache. The NWS responses are about as large as the real
+// ones. If `decoded` is false, the decoded responses are dropped
+// before each render, as if only the responses were cached.
+func benchmarkWarmBundle(b *testing.B, decoded bool) {
+	start := time.Now().UTC().Truncate(time.Hour)
+	periods := []string{}
+	for i := 0; i < 156; i++ {
+		periods = append(periods, fmt.Sprintf(`{"number":%d,"name":"","startTime":"%s","endTime":"%s","isDaytime":true,"temperature":%d,"temperatureUnit":"F","windSpeed":"10 mph","windDirection":"SW","icon":"https://api.weather.gov/icons/land/day/few?size=small","shortForecast":"Sunny","detailedForecast":""}`,
+			i+1, start.Add(time.Duration(i)*time.Hour).Format(time.RFC3339),
+			start.Add(time.Duration(i+1)*time.Hour).Format(time.RFC3339),
+			60+i%20))
+	}
+	values := []string{}
+	for i := 0; i < 156; i++ {
+		values = append(values, fmt.Sprintf(`{"validTime":"%s/PT1H","value":%d}`,
+			start.Add(time.Duration(i)*time.Hour).Format(time.RFC3339), i%100))
+	}
+	layer := fmt.Sprintf(`{"uom":"wmoUnit:percent","values":[%s]}`,
+		strings.Join(values, ","))
+	layers := []string{}
+	for _, name := range []string{"temperature", "dewpoint", "maxTemperature",
+		"minTemperature", "relativeHumidity", "apparentTemperature",
+		"heatIndex", "windChill", "skyCover", "windDirection", "windSpeed",
+		"windGust", "probabilityOfPrecipitation", "quantitativePrecipitation"} {
+		layers = append(layers, fmt.Sprintf(`"%s":%s`, name, layer))
+	}
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("expires",
+			time.Now().Add(time.Hour).Format(time.RFC1123))
+
+		genAt := time.Now().UTC().Format(time.RFC3339)
+		switch r.URL.Path {
+		case "/points/40.0000,-80.0000":
+			fmt.Fprintf(w, `{"properties":{"gridId":"PBZ","gridX":1,"gridY":1,"forecast":"%[1]s/gridpoints/PBZ/1,1/forecast","forecastHourly":"%[1]s/gridpoints/PBZ/1,1/forecast/hourly","forecastGridData":"%[1]s/gridpoints/PBZ/1,1","relativeLocation":{"properties":{"city":"Pittsburgh","state":"PA"}}}}`, ts.URL)
+		case "/gridpoints/PBZ/1,1/forecast":
+			fmt.Fprintf(w, `{"properties":{"generatedAt":"%s","periods":[%s]}}`,
+				genAt, strings.Join(periods[:14], ","))
+		case "/gridpoints/PBZ/1,1/forecast/hourly":
+			fmt.Fprintf(w, `{"properties":{"generatedAt":"%s","periods":[%s]}}`,
+				genAt, strings.Join(periods, ","))
+		case "/gridpoints/PBZ/1,1":
+			fmt.Fprintf(w, `{"properties":{"updateTime":"%s",%s}}`,
+				genAt, strings.Join(layers, ","))
+		case "/alerts/active":
+			fmt.Fprint(w, `{"type":"FeatureCollection","features":[]}`)
+		case "/gridpoints/PBZ/1,1/stations":
+			fmt.Fprint(w, `{"features":[{"properties":{"stationIdentifier":"KPIT"}}]}`)
+		case "/stations/KPIT/observations/latest":
+			fmt.Fprintf(w, `{"properties":{"station":"https://api.weather.gov/stations/KPIT","timestamp":"%s","textDescription":"Sunny","temperature":{"unitCode":"wmoUnit:degC","value":24.4}}}`, genAt)
+		default:
+			http.Error(w, `{"type":"urn:noaa:nws:api:NotFound","title":"Not Found","status":404,"detail":"not found"}`, 404)
+		}
+	}))
+	defer ts.Close()
+	baseUrl, _ = url.Parse(ts.URL)
+
+	UseStores(func(string) (cache.Store, error) {
+		return cache.NewCache(), nil
+	})
+	defer UseStores(func(string) (cache.Store, error) {
+		return cache.NewCache(), nil
+	})
+
+	// Warm up the cache.
+	bundle, nwsErr := GetForecastBundle(context.Background(), 40, -80)
+	if nwsErr != nil || bundle.ForecastGridErr != nil {
+		b.Fatalf("bundle: %v %v", nwsErr, bundle.ForecastGridErr)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !decoded {
+			resetValues()
+		}
+		_, nwsErr := GetForecastBundle(context.Background(), 40, -80)
+		if nwsErr != nil {
+			b.Fatalf("bundle: %v", nwsErr)
+		}
+	}
+}
+
+func BenchmarkWarmBundle(b *testing.B) {
+	benchmarkWarmBundle(b, true)
+}
+
+func BenchmarkWarmBundleUndecoded(b *testing.B) {
+	benchmarkWarmBundle(b, false)
+}
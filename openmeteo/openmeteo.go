@@ -0,0 +1,157 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+// Functions for accessing the Open-Meteo forecast API.
+package openmeteo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"ricketyspace.net/peach/cache"
+	"ricketyspace.net/peach/client"
+)
+
+// Default Open-Meteo forecast API URL.
+const defaultUrl = "https://api.open-meteo.com/v1/forecast"
+
+// Number of days in the forecast.
+const forecastDays = 7
+
+// How long forecasts are cached. Open-Meteo updates its forecasts
+// every hour.
+const forecastExpiry = 15 * time.Minute
+
+// Forecasts, by coordinates.
+var fCache = cache.NewBounded[string, *Forecast](256, 0, nil)
+
+// Represents a response from the Open-Meteo forecast API. Temperatures
+// are in °F, wind speeds in mph and times in seconds since the epoch.
+type Forecast struct {
+	Latitude             float64
+	Longitude            float64
+	UtcOffsetSeconds     int    `json:"utc_offset_seconds"`
+	Timezone             string // For instance, Europe/Berlin.
+	TimezoneAbbreviation string `json:"timezone_abbreviation"`
+	Current              Current
+	Hourly               Hourly
+}
+
+// Represents the current conditions in the Forecast.
+type Current struct {
+	Time             int64
+	Temperature      float64 `json:"temperature_2m"`
+	RelativeHumidity float64 `json:"relative_humidity_2m"`
+	Dewpoint         float64 `json:"dew_point_2m"`
+	WeatherCode      int     `json:"weather_code"` // WMO weather code.
+	WindSpeed        float64 `json:"wind_speed_10m"`
+	WindDirection    float64 `json:"wind_direction_10m"` // Degrees.
+	IsDay            int     `json:"is_day"`             // 1 during the day.
+}
+
+// Represents the hourly forecast in the Forecast. Each field has a
+// value for every hour in Time.
+type Hourly struct {
+	Time                     []int64
+	Temperature              []float64 `json:"temperature_2m"`
+	ApparentTemperature      []float64 `json:"apparent_temperature"`
+	RelativeHumidity         []float64 `json:"relative_humidity_2m"`
+	Dewpoint                 []float64 `json:"dew_point_2m"`
+	PrecipitationProbability []float64 `json:"precipitation_probability"`
+	WeatherCode              []int     `json:"weather_code"`
+	WindSpeed                []float64 `json:"wind_speed_10m"`
+	WindDirection            []float64 `json:"wind_direction_10m"`
+	IsDay                    []int     `json:"is_day"`
+}
+
+// Represents an error response from the Open-Meteo API.
+type errorResponse struct {
+	Reason string
+}
+
+// Returns the Open-Meteo forecast API URL; $PEACH_OPENMETEO_URL if it
+// is set.
+func Url() (*url.URL, error) {
+	u := os.Getenv("PEACH_OPENMETEO_URL")
+	if len(u) == 0 {
+		u = defaultUrl
+	}
+	return url.Parse(u)
+}
+
+// Returns the forecast for coordinates `lat`,`lng`, in the time zone
+// of the coordinates.
+func GetForecast(ctx context.Context, lat, lng float32) (*Forecast, error) {
+	ll := fmt.Sprintf("%.4f,%.4f", lat, lng)
+	if f := fCache.Get(ll); f != nil {
+		return f, nil
+	}
+
+	// Construct request.
+	u, err := Url()
+	if err != nil {
+		return nil, fmt.Errorf("forecast: %v", err)
+	}
+	q := url.Values{}
+	q.Add("latitude", fmt.Sprintf("%.4f", lat))
+	q.Add("longitude", fmt.Sprintf("%.4f", lng))
+	q.Add("current", "temperature_2m,relative_humidity_2m,dew_point_2m,"+
+		"weather_code,wind_speed_10m,wind_direction_10m,is_day")
+	q.Add("hourly", "temperature_2m,apparent_temperature,"+
+		"relative_humidity_2m,dew_point_2m,precipitation_probability,"+
+		"weather_code,wind_speed_10m,wind_direction_10m,is_day")
+	q.Add("temperature_unit", "fahrenheit")
+	q.Add("wind_speed_unit", "mph")
+	q.Add("precipitation_unit", "inch")
+	q.Add("timeformat", "unixtime")
+	q.Add("timezone", "auto")
+	q.Add("forecast_days", fmt.Sprintf("%d", forecastDays))
+	u.RawQuery = q.Encode()
+
+	// Make request.
+	resp, err := client.Get(ctx, u.String())
+	if err != nil {
+		return nil, fmt.Errorf("forecast: get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Parse response body.
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("forecast: body: %v", err)
+	}
+
+	// Check if the request failed.
+	if resp.StatusCode != 200 {
+		e := errorResponse{}
+		if json.Unmarshal(body, &e) == nil && len(e.Reason) > 0 {
+			return nil, fmt.Errorf("forecast: %s", e.Reason)
+		}
+		return nil, fmt.Errorf("forecast: %s", body)
+	}
+
+	// Unmarshal
+	f := new(Forecast)
+	err = json.Unmarshal(body, f)
+	if err != nil {
+		return nil, fmt.Errorf("forecast: decode: %v", err)
+	}
+	if len(f.Hourly.Time) == 0 {
+		return nil, fmt.Errorf("forecast: no hourly forecast at %s", ll)
+	}
+	fCache.Set(ll, f, time.Now().Add(forecastExpiry))
+	return f, nil
+}
+
+// Returns the time zone of the forecast.
+func (f *Forecast) Location() *time.Location {
+	if loc, err := time.LoadLocation(f.Timezone); err == nil {
+		return loc
+	}
+	return time.FixedZone(f.TimezoneAbbreviation, f.UtcOffsetSeconds)
+}
@@ -0,0 +1,94 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package openmeteo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUrl(t *testing.T) {
+	os.Setenv("PEACH_OPENMETEO_URL", "")
+	u, err := Url()
+	if err != nil || u.String() != defaultUrl {
+		t.Errorf("url: %v %v", u, err)
+		return
+	}
+
+	os.Setenv("PEACH_OPENMETEO_URL", "http://localhost:8080/v1/forecast")
+	u, err = Url()
+	if err != nil || u.String() != "http://localhost:8080/v1/forecast" {
+		t.Errorf("url: %v %v", u, err)
+		return
+	}
+}
+
+func TestGetForecast(t *testing.T) {
+	queries := []url.Values{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		queries = append(queries, q)
+		switch q.Get("latitude") {
+		case "52.5200":
+			fmt.Fprint(w, `{"latitude":52.52,"longitude":13.419998,"utc_offset_seconds":7200,"timezone":"Europe/Berlin","timezone_abbreviation":"CEST","current":{"time":1718454600,"temperature_2m":68.5,"relative_humidity_2m":55,"dew_point_2m":51.8,"weather_code":2,"wind_speed_10m":8.4,"wind_direction_10m":250,"is_day":1},"hourly":{"time":[1718402400,1718406000],"temperature_2m":[60.1,59.4],"apparent_temperature":[58.2,57.9],"relative_humidity_2m":[70,72],"dew_point_2m":[50.2,50.4],"precipitation_probability":[10,null],"weather_code":[3,61],"wind_speed_10m":[5.1,4.8],"wind_direction_10m":[240,245],"is_day":[0,0]}}`)
+		default:
+			w.WriteHeader(400)
+			fmt.Fprint(w, `{"error":true,"reason":"Latitude must be in range of -90 to 90°. Given: 95.0."}`)
+		}
+	}))
+	defer ts.Close()
+	os.Setenv("PEACH_OPENMETEO_URL", ts.URL+"/v1/forecast")
+
+	f, err := GetForecast(context.Background(), 52.52, 13.405)
+	if err != nil {
+		t.Errorf("forecast: %v", err)
+		return
+	}
+	if f.Current.Temperature != 68.5 || f.Current.WeatherCode != 2 {
+		t.Errorf("forecast: current: %v", f.Current)
+		return
+	}
+	if len(f.Hourly.Time) != 2 || f.Hourly.WeatherCode[1] != 61 ||
+		f.Hourly.PrecipitationProbability[1] != 0 {
+		t.Errorf("forecast: hourly: %v", f.Hourly)
+		return
+	}
+	if f.Location().String() != "Europe/Berlin" {
+		t.Errorf("forecast: location: %v", f.Location())
+		return
+	}
+	q := queries[0]
+	if q.Get("temperature_unit") != "fahrenheit" ||
+		q.Get("wind_speed_unit") != "mph" || q.Get("timezone") != "auto" {
+		t.Errorf("forecast: query: %v", q)
+		return
+	}
+
+	// Cached.
+	_, err = GetForecast(context.Background(), 52.52, 13.405)
+	if err != nil || len(queries) != 1 {
+		t.Errorf("forecast: not cached: %v %v", err, queries)
+		return
+	}
+
+	// Error.
+	_, err = GetForecast(context.Background(), 95, 13.405)
+	if err == nil || err.Error() != "forecast: Latitude must be in range of -90 to 90°. Given: 95.0." {
+		t.Errorf("forecast: error: %v", err)
+		return
+	}
+
+	// Time zone that is not known.
+	f.Timezone = "Nowhere/Nowhere"
+	if _, offset := time.Unix(0, 0).In(f.Location()).Zone(); offset != 7200 {
+		t.Errorf("forecast: fixed zone: %v", f.Location())
+		return
+	}
+}
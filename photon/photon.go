@@ -5,23 +5,28 @@
 package photon
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
+	"path"
 	"strings"
+	"time"
 
+	"ricketyspace.net/peach/cache"
 	"ricketyspace.net/peach/client"
-	"ricketyspace.net/peach/nws"
+	"ricketyspace.net/peach/geo"
 )
 
 // Coordinates.
-type Coordinates struct {
-	Lat  float32
-	Lng  float32
-	Name string
-}
+type Coordinates = geo.Coordinates
+
+// Photon as a geo.Geocoder.
+type Geocoder struct{}
+
+var _ geo.Geocoder = Geocoder{}
 
 // Represents a response from the Photon API.
 type Response struct {
@@ -43,9 +48,18 @@ type Geometry struct {
 type Properties struct {
 	CountryCode string
 	Name        string
+	City        string
 	State       string
+	Country     string
+	Postcode    string
 }
 
+// Places found by Reverse, by coordinates.
+var rCache = cache.NewBounded[string, *Coordinates](1024, 0, nil)
+
+// How long places found by Reverse are cached.
+const reverseExpiry = 24 * time.Hour
+
 // Returns true of geocoding is possible.
 func Enabled() bool {
 	return len(os.Getenv("PEACH_PHOTON_URL")) > 0
@@ -66,8 +80,18 @@ func Url() (*url.URL, error) {
 	return pu, nil
 }
 
+// Returns the URL of the Photon reverse geocoding API.
+func reverseUrl() (*url.URL, error) {
+	pu, err := Url()
+	if err != nil {
+		return nil, err
+	}
+	pu.Path = path.Join(path.Dir(pu.Path), "reverse")
+	return pu, nil
+}
+
 // Returns a list of matching Coordinates for a given location.
-func Geocode(location string) ([]Coordinates, error) {
+func Geocode(ctx context.Context, location string) ([]Coordinates, error) {
 	mCoords := []Coordinates{} // Matching coordinates
 	location = strings.TrimSpace(location)
 	if len(location) < 2 {
@@ -88,50 +112,190 @@ func Geocode(location string) ([]Coordinates, error) {
 	u.RawQuery = q.Encode()
 
 	// Make request.
-	resp, err := client.Get(u.String())
+	r, err := get(ctx, u)
 	if err != nil {
-		return mCoords, fmt.Errorf("geocode: get: %v", err)
+		return mCoords, fmt.Errorf("geocode: %v", err)
 	}
 
-	// Parse response body.
-	body, err := io.ReadAll(resp.Body)
+	// Make matching coordinates list.
+	names := map[string]bool{}
+	for _, feature := range r.Features {
+		c := Coordinates{}
+		c.Lat = feature.Geometry.Coordinates[1]
+		c.Lng = feature.Geometry.Coordinates[0]
+		c.Name = placeName(feature.Properties.Name, feature.Properties)
+		c.State, c.CountryCode = placeState(feature.Properties)
+		if names[c.Name] {
+			continue // skip.
+		}
+		names[c.Name] = true
+
+		mCoords = append(mCoords, c)
+	}
+	return mCoords, nil
+}
+
+// Returns the name of place `name` whose properties are `p`; for
+// instance, Tiffin, Ohio or Toronto, Ontario, Canada. Places outside
+// the US are named with their country, and without their state if it
+// has the same name; for instance, Berlin, Germany.
+func placeName(name string, p Properties) string {
+	parts := []string{name}
+	if len(p.State) > 0 && (p.CountryCode == "US" || p.State != name) {
+		parts = append(parts, p.State)
+	}
+	if p.CountryCode != "US" && len(p.Country) > 0 {
+		parts = append(parts, p.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Returns the state code and the country code of a place whose
+// properties are `p`. The state code is empty for places outside the
+// US.
+func placeState(p Properties) (string, string) {
+	if p.CountryCode != "US" {
+		return "", p.CountryCode
+	}
+	return geo.StateCode(p.State), p.CountryCode
+}
+
+// Returns the city, town or village at coordinates `lat`,`lng`; for
+// instance, Tiffin, Ohio or Toronto, Ontario, Canada.
+func Reverse(ctx context.Context, lat, lng float32) (*Coordinates, error) {
+	ll := fmt.Sprintf("%.4f,%.4f", lat, lng)
+	if c := rCache.Get(ll); c != nil {
+		return c, nil
+	}
+
+	// Construct request.
+	u, err := reverseUrl()
 	if err != nil {
-		return mCoords, fmt.Errorf("geocode: body: %v", err)
+		return nil, fmt.Errorf("reverse: %v", err)
 	}
+	q := url.Values{}
+	q.Add("lat", fmt.Sprintf("%.4f", lat))
+	q.Add("lon", fmt.Sprintf("%.4f", lng))
+	q.Add("osm_tag", "place:city")
+	q.Add("osm_tag", "place:town")
+	q.Add("osm_tag", "place:village")
+	q.Add("limit", "1")
+	u.RawQuery = q.Encode()
 
-	// Check if the request failed.
-	if resp.StatusCode != 200 {
-		return mCoords, fmt.Errorf("geocode: %s", body)
+	// Make request.
+	r, err := get(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("reverse: %v", err)
+	}
+	if len(r.Features) == 0 {
+		return nil, fmt.Errorf("reverse: no place at %s", ll)
 	}
+	feature := r.Features[0]
 
-	// Unmarshal
-	r := new(Response)
-	err = json.Unmarshal(body, r)
+	// The place may be a part of a city; for instance, a
+	// neighbourhood.
+	name := feature.Properties.City
+	if len(name) == 0 {
+		name = feature.Properties.Name
+	}
+	if len(name) == 0 {
+		return nil, fmt.Errorf("reverse: place at %s has no name", ll)
+	}
+	c := &Coordinates{
+		Lat:  lat,
+		Lng:  lng,
+		Name: placeName(name, feature.Properties),
+	}
+	c.State, c.CountryCode = placeState(feature.Properties)
+	rCache.Set(ll, c, time.Now().Add(reverseExpiry))
+	return c, nil
+}
+
+// Returns the centroid of five digit ZIP code `zip`, named after its
+// city; for instance, Tiffin, Ohio.
+func Zip(ctx context.Context, zip string) ([]Coordinates, error) {
+	mCoords := []Coordinates{} // Matching coordinates
+
+	// Construct request.
+	u, err := Url()
 	if err != nil {
-		return mCoords, fmt.Errorf("geocode: decode: %v", err)
+		return mCoords, fmt.Errorf("zip: %v", err)
+	}
+	q := url.Values{}
+	q.Add("q", zip)
+	q.Add("osm_tag", "place:postcode")
+	q.Add("limit", "10")
+	u.RawQuery = q.Encode()
+
+	// Make request.
+	r, err := get(ctx, u)
+	if err != nil {
+		return mCoords, fmt.Errorf("zip: %v", err)
 	}
 
-	// Make matching coordinates list.
-	names := map[string]bool{}
 	for _, feature := range r.Features {
-		if feature.Properties.CountryCode != "US" {
+		p := feature.Properties
+		if p.CountryCode != "US" || p.Postcode != zip {
 			continue // skip
 		}
 
 		c := Coordinates{}
 		c.Lat = feature.Geometry.Coordinates[1]
 		c.Lng = feature.Geometry.Coordinates[0]
-		c.Name = fmt.Sprintf("%s, %s",
-			feature.Properties.Name,
-			feature.Properties.State,
-		)
-		if names[c.Name] {
-			continue // skip.
+		c.Zip = zip
+		c.Name = zip
+		c.State, c.CountryCode = placeState(p)
+		if len(p.City) > 0 && len(p.State) > 0 {
+			c.Name = fmt.Sprintf("%s, %s", p.City, p.State)
+		} else if rc, err := Reverse(ctx, c.Lat, c.Lng); err == nil {
+			c.Name = rc.Name
 		}
-		names[c.Name] = true
 
 		mCoords = append(mCoords, c)
-		go nws.GetForecastBundle(c.Lat, c.Lng)
+		break // A ZIP code has one centroid.
 	}
 	return mCoords, nil
 }
+
+// Makes a request to Photon API URL `u`.
+func get(ctx context.Context, u *url.URL) (*Response, error) {
+	resp, err := client.Get(ctx, u.String())
+	if err != nil {
+		return nil, fmt.Errorf("get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Parse response body.
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %v", err)
+	}
+
+	// Check if the request failed.
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%s", body)
+	}
+
+	// Unmarshal
+	r := new(Response)
+	err = json.Unmarshal(body, r)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %v", err)
+	}
+	return r, nil
+}
+
+// See Geocode.
+func (Geocoder) Geocode(ctx context.Context, location string) ([]Coordinates, error) {
+	return Geocode(ctx, location)
+}
+
+// See Reverse.
+func (Geocoder) Reverse(ctx context.Context, lat, lng float32) (*Coordinates, error) {
+	return Reverse(ctx, lat, lng)
+}
+
+// See Zip.
+func (Geocoder) Zip(ctx context.Context, zip string) ([]Coordinates, error) {
+	return Zip(ctx, zip)
+}
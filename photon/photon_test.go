@@ -4,6 +4,11 @@
 package photon
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 )
@@ -43,7 +48,7 @@ func TestPhotonUrl(t *testing.T) {
 
 func TestGeocode(t *testing.T) {
 	os.Setenv("PEACH_PHOTON_URL", "https://photon.komoot.io")
-	mCoords, err := Geocode("Tiffin,OH")
+	mCoords, err := Geocode(context.Background(), "Tiffin,OH")
 	if err != nil {
 		t.Errorf("%v", err)
 		return
@@ -65,3 +70,153 @@ func TestGeocode(t *testing.T) {
 		return
 	}
 }
+
+func TestReverse(t *testing.T) {
+	queries := []url.Values{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/reverse" {
+			http.Error(w, "not found", 404)
+			return
+		}
+		queries = append(queries, r.URL.Query())
+		switch r.URL.Query().Get("lat") {
+		case "41.1145":
+			fmt.Fprint(w, `{"features":[{"geometry":{"coordinates":[-83.1779537,41.114485],"type":"Point"},"type":"Feature","properties":{"osm_type":"R","name":"Tiffin","country":"United States","countrycode":"US","state":"Ohio","type":"city"}}],"type":"FeatureCollection"}`)
+		case "41.4993":
+			fmt.Fprint(w, `{"features":[{"geometry":{"coordinates":[-81.6944,41.4993],"type":"Point"},"type":"Feature","properties":{"name":"Downtown","city":"Cleveland","countrycode":"US","state":"Ohio","type":"district"}}],"type":"FeatureCollection"}`)
+		case "43.6532":
+			fmt.Fprint(w, `{"features":[{"geometry":{"coordinates":[-79.3832,43.6532],"type":"Point"},"type":"Feature","properties":{"name":"Toronto","country":"Canada","countrycode":"CA","state":"Ontario","type":"city"}}],"type":"FeatureCollection"}`)
+		default:
+			fmt.Fprint(w, `{"features":[],"type":"FeatureCollection"}`)
+		}
+	}))
+	defer ts.Close()
+	os.Setenv("PEACH_PHOTON_URL", ts.URL)
+
+	c, err := Reverse(context.Background(), 41.114485, -83.1779537)
+	if err != nil {
+		t.Errorf("reverse: %v", err)
+		return
+	}
+	if c.Name != "Tiffin, Ohio" || !c.InState("OH") {
+		t.Errorf("reverse: name: %v", c)
+		return
+	}
+	if len(queries) != 1 || queries[0].Get("lon") != "-83.1780" {
+		t.Errorf("reverse: queries: %v", queries)
+		return
+	}
+
+	// Cached.
+	c, err = Reverse(context.Background(), 41.114485, -83.1779537)
+	if err != nil || c.Name != "Tiffin, Ohio" || len(queries) != 1 {
+		t.Errorf("reverse: not cached: %v %v %v", c, err, queries)
+		return
+	}
+
+	// Part of a city.
+	c, err = Reverse(context.Background(), 41.4993, -81.6944)
+	if err != nil || c.Name != "Cleveland, Ohio" {
+		t.Errorf("reverse: city: %v %v", c, err)
+		return
+	}
+
+	// Outside the US.
+	c, err = Reverse(context.Background(), 43.6532, -79.3832)
+	if err != nil || c.Name != "Toronto, Ontario, Canada" {
+		t.Errorf("reverse: outside us: %v %v", c, err)
+		return
+	}
+
+	// Nothing there.
+	c, err = Reverse(context.Background(), 0, 0)
+	if err == nil {
+		t.Errorf("reverse: nothing: %v", c)
+		return
+	}
+}
+
+func TestZip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path == "/reverse" {
+			fmt.Fprint(w, `{"features":[{"geometry":{"coordinates":[-83.1779537,41.114485],"type":"Point"},"type":"Feature","properties":{"name":"Tiffin","countrycode":"US","state":"Ohio","type":"city"}}],"type":"FeatureCollection"}`)
+			return
+		}
+		if q.Get("osm_tag") != "place:postcode" {
+			http.Error(w, "bad osm_tag", 400)
+			return
+		}
+		switch q.Get("q") {
+		case "44883":
+			fmt.Fprint(w, `{"features":[{"geometry":{"coordinates":[11.0,49.0],"type":"Point"},"type":"Feature","properties":{"postcode":"44883","countrycode":"DE","type":"other"}},{"geometry":{"coordinates":[-83.18,41.12],"type":"Point"},"type":"Feature","properties":{"postcode":"44883","city":"Tiffin","countrycode":"US","state":"Ohio","type":"other"}}],"type":"FeatureCollection"}`)
+		case "44884":
+			fmt.Fprint(w, `{"features":[{"geometry":{"coordinates":[-83.17,41.11],"type":"Point"},"type":"Feature","properties":{"postcode":"44884","countrycode":"US","state":"Ohio","type":"other"}}],"type":"FeatureCollection"}`)
+		default:
+			fmt.Fprint(w, `{"features":[],"type":"FeatureCollection"}`)
+		}
+	}))
+	defer ts.Close()
+	os.Setenv("PEACH_PHOTON_URL", ts.URL)
+
+	m, err := Zip(context.Background(), "44883")
+	if err != nil {
+		t.Errorf("zip: %v", err)
+		return
+	}
+	if len(m) != 1 || m[0].Name != "Tiffin, Ohio" || m[0].Zip != "44883" ||
+		!m[0].InState("OH") {
+		t.Errorf("zip: %v", m)
+		return
+	}
+	if m[0].Lat != 41.12 || m[0].Lng != -83.18 {
+		t.Errorf("zip: coordinates: %v", m[0])
+		return
+	}
+
+	// No city; named with Reverse.
+	m, err = Zip(context.Background(), "44884")
+	if err != nil || len(m) != 1 || m[0].Name != "Tiffin, Ohio" {
+		t.Errorf("zip: no city: %v %v", m, err)
+		return
+	}
+
+	// Not found.
+	m, err = Zip(context.Background(), "00000")
+	if err != nil || len(m) != 0 {
+		t.Errorf("zip: not found: %v %v", m, err)
+		return
+	}
+}
+
+func TestPlaceName(t *testing.T) {
+	for name, p := range map[string]Properties{
+		"Tiffin, Ohio":             {Name: "Tiffin", State: "Ohio", CountryCode: "US", Country: "United States"},
+		"New York, New York":       {Name: "New York", State: "New York", CountryCode: "US"},
+		"Toronto, Ontario, Canada": {Name: "Toronto", State: "Ontario", CountryCode: "CA", Country: "Canada"},
+		"Berlin, Germany":          {Name: "Berlin", State: "Berlin", CountryCode: "DE", Country: "Germany"},
+		"Monaco":                   {Name: "Monaco", CountryCode: "MC"},
+	} {
+		if n := placeName(p.Name, p); n != name {
+			t.Errorf("place name: %v: %v", p, n)
+			return
+		}
+	}
+}
+
+func TestPlaceState(t *testing.T) {
+	for _, c := range []struct {
+		p              Properties
+		state, country string
+	}{
+		{Properties{State: "Ohio", CountryCode: "US"}, "OH", "US"},
+		{Properties{State: "Ontario", CountryCode: "CA"}, "", "CA"},
+		{Properties{State: "Georgia", CountryCode: "GE"}, "", "GE"},
+	} {
+		state, country := placeState(c.p)
+		if state != c.state || country != c.country {
+			t.Errorf("place state: %v: %v %v", c.p, state, country)
+			return
+		}
+	}
+}
@@ -4,29 +4,42 @@
 package search
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
-	"ricketyspace.net/peach/photon"
+	"ricketyspace.net/peach/geo"
+	"ricketyspace.net/peach/nws"
 	"ricketyspace.net/peach/version"
 )
 
+// Time budget for fetching the forecast of a matching location in the
+// background.
+const prefetchTimeout = 30 * time.Second
+
 type Search struct {
 	Title          string
 	Version        string
+	Canonical      string // Canonical URL path of the page.
 	Location       string
 	Message        string
-	MatchingCoords []photon.Coordinates
+	MatchingCoords []geo.Coordinates
 	Enabled        bool
 }
 
-func NewSearch(r *http.Request) (*Search, error, int) {
+// Makes the search page; locations are looked up with geocoder `g`.
+// Search is disabled if `g` is nil. The forecasts of the matching
+// locations in the US are fetched in the background, so that the one
+// that is picked shows up quickly.
+func NewSearch(r *http.Request, g geo.Geocoder) (*Search, error, int) {
 	s := new(Search)
 	s.Title = "search"
 	s.Version = version.Version
-	s.Enabled = photon.Enabled()
+	s.Canonical = "/search"
+	s.Enabled = g != nil
 
 	if !s.Enabled {
 		return s, fmt.Errorf("search disabled"), 404
@@ -47,7 +60,11 @@ func NewSearch(r *http.Request) (*Search, error, int) {
 	}
 
 	// Try to fetch matching coordinates.
-	s.MatchingCoords, err = photon.Geocode(location)
+	if zip, ok := geo.ParseZip(location); ok {
+		s.MatchingCoords, err = g.Zip(r.Context(), zip)
+	} else {
+		s.MatchingCoords, err = g.Geocode(r.Context(), location)
+	}
 	if err != nil {
 		log.Printf("search: geocode: %v", err)
 		s.Message = "unable to lookup location"
@@ -57,5 +74,18 @@ func NewSearch(r *http.Request) (*Search, error, int) {
 		s.Message = "location not found"
 		return s, nil, 200
 	}
+	for _, c := range s.MatchingCoords {
+		if c.CountryCode == "US" {
+			go prefetch(c)
+		}
+	}
 	return s, nil, 200
 }
+
+// Fetches the forecast for `c` to warm up the NWS cache. It runs
+// independent of the request that found `c`.
+func prefetch(c geo.Coordinates) {
+	ctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+	defer cancel()
+	nws.GetForecastBundle(ctx, c.Lat, c.Lng)
+}
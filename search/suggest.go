@@ -0,0 +1,159 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ricketyspace.net/peach/cache"
+	"ricketyspace.net/peach/geo"
+)
+
+// Queries shorter than this get no suggestions.
+const minQueryLength = 2
+
+// Results with fewer suggestions than this are complete; suggestions
+// for longer queries that start with the same text are a subset of
+// them.
+const maxSuggestions = 10
+
+// How long suggestions are cached.
+const suggestExpiry = 24 * time.Hour
+
+// Time budget for a lookup of suggestions. A lookup is shared by the
+// callers that make the same query, so it is not cut short by the
+// context of any one of them.
+const lookupTimeout = 15 * time.Second
+
+// Suggestions, by normalized query.
+var suggestCache = cache.NewBounded[string, []geo.Coordinates](4096, 0, nil)
+
+// A lookup of suggestions that is in flight.
+type lookup struct {
+	done    chan int // Closed when the lookup is done.
+	mCoords []geo.Coordinates
+	err     error
+}
+
+// Lookups in flight.
+var lookups = struct {
+	sema  chan int // Semaphore for read/write access to the lookups.
+	calls map[string]*lookup
+}{
+	sema:  make(chan int, 1),
+	calls: make(map[string]*lookup),
+}
+
+// Returns the places that match query `q`, which is the start of a
+// location, for suggesting while the location is typed.
+//
+// Suggestions are cached. A query is answered from the cached
+// suggestions of a shorter query that it starts with, if those are
+// complete; concurrent lookups of the same query share a single
+// request to geocoder `g`, which runs in the background until it is
+// done even if the callers stop waiting for it.
+func Suggest(ctx context.Context, g geo.Geocoder, q string) ([]geo.Coordinates, error, int) {
+	if g == nil {
+		return nil, fmt.Errorf("search disabled"), 404
+	}
+	q = normalize(q)
+	if len(q) < minQueryLength {
+		return []geo.Coordinates{}, nil, 200
+	}
+
+	if mCoords, ok := cachedSuggestions(q); ok {
+		return mCoords, nil, 200
+	}
+
+	// Join the lookup in flight or start a new one.
+	lookups.sema <- 1
+	l, joined := lookups.calls[q]
+	if !joined {
+		l = &lookup{done: make(chan int)}
+		lookups.calls[q] = l
+	}
+	<-lookups.sema
+	if !joined {
+		go l.run(g, q)
+	}
+
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+		return nil, ctx.Err(), 504
+	}
+	if l.err != nil {
+		log.Printf("search: suggest: %s: %v", q, l.err)
+		return nil, fmt.Errorf("unable to lookup location"), 502
+	}
+	return l.mCoords, nil, 200
+}
+
+// Looks up the places that match query `q` and caches them.
+func (l *lookup) run(g geo.Geocoder, q string) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	if zip, ok := geo.ParseZip(q); ok {
+		l.mCoords, l.err = g.Zip(ctx, zip)
+	} else if isDigits(q) {
+		l.mCoords = []geo.Coordinates{} // Part of a ZIP code.
+	} else {
+		l.mCoords, l.err = g.Geocode(ctx, q)
+	}
+	if l.err == nil {
+		suggestCache.Set(q, l.mCoords, time.Now().Add(suggestExpiry))
+	}
+
+	lookups.sema <- 1
+	delete(lookups.calls, q)
+	<-lookups.sema
+	close(l.done)
+}
+
+// Returns the cached suggestions for query `q`; false if there are
+// none.
+func cachedSuggestions(q string) ([]geo.Coordinates, bool) {
+	if mCoords := suggestCache.Get(q); mCoords != nil {
+		return mCoords, true
+	}
+	if isDigits(q) {
+		return nil, false // Places are not named by digits.
+	}
+
+	// Find the suggestions for the longest shorter query that are
+	// complete.
+	for n := len(q) - 1; n >= minQueryLength; n-- {
+		mCoords := suggestCache.Get(q[:n])
+		if mCoords == nil || len(mCoords) >= maxSuggestions {
+			continue
+		}
+		matching := []geo.Coordinates{}
+		for _, c := range mCoords {
+			if strings.HasPrefix(normalize(c.Name), q) {
+				matching = append(matching, c)
+			}
+		}
+		if len(matching) == 0 {
+			// The geocoder may match names more loosely.
+			return nil, false
+		}
+		return matching, true
+	}
+	return nil, false
+}
+
+// Returns query `q` in lower case with its spaces collapsed.
+func normalize(q string) string {
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+// Returns true if `s` is made up of digits, spaces and dashes; for
+// instance, part of a ZIP+4 code.
+func isDigits(s string) bool {
+	return len(strings.Trim(s, "0123456789- ")) == 0
+}
@@ -0,0 +1,180 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package search
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ricketyspace.net/peach/cache"
+	"ricketyspace.net/peach/geo"
+)
+
+// A geo.Geocoder that counts its lookups.
+type countingGeocoder struct {
+	mu      sync.Mutex
+	queries []string
+	delay   time.Duration
+}
+
+func (g *countingGeocoder) Geocode(ctx context.Context, location string) ([]geo.Coordinates, error) {
+	g.mu.Lock()
+	g.queries = append(g.queries, location)
+	g.mu.Unlock()
+	select {
+	case <-time.After(g.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	mCoords := []geo.Coordinates{}
+	for _, name := range []string{"Tiffin, Ohio", "Tiffin, Iowa", "Toledo, Ohio"} {
+		if strings.HasPrefix(strings.ToLower(name), location) {
+			mCoords = append(mCoords, geo.Coordinates{Name: name})
+		}
+	}
+	return mCoords, nil
+}
+
+func (g *countingGeocoder) Reverse(ctx context.Context, lat, lng float32) (*geo.Coordinates, error) {
+	return nil, nil
+}
+
+func (g *countingGeocoder) Zip(ctx context.Context, zip string) ([]geo.Coordinates, error) {
+	g.mu.Lock()
+	g.queries = append(g.queries, zip)
+	g.mu.Unlock()
+	return []geo.Coordinates{{Name: "Tiffin, Ohio", Zip: zip}}, nil
+}
+
+func (g *countingGeocoder) lookups() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.queries)
+}
+
+// Empties the suggestions cache.
+func resetSuggestions() {
+	suggestCache = cache.NewBounded[string, []geo.Coordinates](4096, 0, nil)
+}
+
+func TestSuggest(t *testing.T) {
+	resetSuggestions()
+	g := new(countingGeocoder)
+	ctx := context.Background()
+
+	mCoords, err, _ := Suggest(ctx, g, "Ti")
+	if err != nil || len(mCoords) != 2 {
+		t.Errorf("suggest: ti: %v %v", mCoords, err)
+		return
+	}
+
+	// Longer queries are answered from the suggestions for "ti".
+	for _, q := range []string{"tif", "TIFFIN", "tiffin,", "tiffin, o"} {
+		mCoords, err, _ = Suggest(ctx, g, q)
+		if err != nil || len(mCoords) < 1 {
+			t.Errorf("suggest: %s: %v %v", q, mCoords, err)
+			return
+		}
+	}
+	mCoords, _, _ = Suggest(ctx, g, "tiffin, o")
+	if len(mCoords) != 1 || mCoords[0].Name != "Tiffin, Ohio" {
+		t.Errorf("suggest: tiffin, o: %v", mCoords)
+		return
+	}
+	if g.lookups() != 1 {
+		t.Errorf("suggest: lookups: %v", g.queries)
+		return
+	}
+
+	// Nothing cached starts with "to".
+	mCoords, _, _ = Suggest(ctx, g, "to")
+	if len(mCoords) != 1 || g.lookups() != 2 {
+		t.Errorf("suggest: to: %v %v", mCoords, g.queries)
+		return
+	}
+
+	// Too short; part of a ZIP code.
+	for _, q := range []string{"t", " ", "448", "44883-12"} {
+		mCoords, err, _ = Suggest(ctx, g, q)
+		if err != nil || len(mCoords) != 0 {
+			t.Errorf("suggest: %q: %v %v", q, mCoords, err)
+			return
+		}
+	}
+	mCoords, _, _ = Suggest(ctx, g, "44883")
+	if len(mCoords) != 1 || mCoords[0].Zip != "44883" {
+		t.Errorf("suggest: 44883: %v", mCoords)
+		return
+	}
+	if g.lookups() != 3 {
+		t.Errorf("suggest: lookups: %v", g.queries)
+		return
+	}
+
+	// Disabled.
+	_, err, status := Suggest(ctx, nil, "tiffin")
+	if err == nil || status != 404 {
+		t.Errorf("suggest: disabled: %v %v", err, status)
+		return
+	}
+}
+
+func TestSuggestCoalesces(t *testing.T) {
+	resetSuggestions()
+	g := &countingGeocoder{delay: 50 * time.Millisecond}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mCoords, err, _ := Suggest(context.Background(), g, "bowl")
+			if err != nil || len(mCoords) != 0 {
+				t.Errorf("suggest: bowl: %v %v", mCoords, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if g.lookups() != 1 {
+		t.Errorf("suggest: lookups: %v", g.queries)
+		return
+	}
+}
+
+func TestSuggestLeaderCanceled(t *testing.T) {
+	resetSuggestions()
+	g := &countingGeocoder{delay: 50 * time.Millisecond}
+
+	// The caller that starts the lookup gives up on it.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan int)
+	go func() {
+		defer close(done)
+		_, err, status := Suggest(ctx, g, "tif")
+		if err == nil || status != 504 {
+			t.Errorf("suggest: leader: %v %v", err, status)
+		}
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	// The caller that joins it still gets the suggestions.
+	mCoords, err, _ := Suggest(context.Background(), g, "tif")
+	if err != nil || len(mCoords) != 2 {
+		t.Errorf("suggest: follower: %v %v", mCoords, err)
+		return
+	}
+	<-done
+	if g.lookups() != 1 {
+		t.Errorf("suggest: lookups: %v", g.queries)
+		return
+	}
+	if mCoords, ok := cachedSuggestions("tif"); !ok || len(mCoords) != 2 {
+		t.Errorf("suggest: not cached: %v", mCoords)
+		return
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: ISC
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+
+// Suggests locations while they are typed in the search box. The
+// search form works without it.
+(function () {
+    'use strict';
+
+    // Wait this long after the last key press before looking up
+    // suggestions, in milliseconds.
+    var debounce = 250;
+
+    var input = document.querySelector('.search-form .location');
+    var list = document.querySelector('.suggestions');
+    if (!input || !list || !window.fetch) {
+        return;
+    }
+
+    var timer = null;
+    var controller = null;
+
+    function clear() {
+        while (list.firstChild) {
+            list.removeChild(list.firstChild);
+        }
+    }
+
+    function show(matches) {
+        clear();
+        matches.forEach(function (c) {
+            var a = document.createElement('a');
+            a.href = '/' + c.lat.toFixed(4) + ',' + c.lng.toFixed(4);
+            if (c.zip) {
+                var zip = document.createElement('span');
+                zip.className = 'zip';
+                zip.textContent = c.zip;
+                a.appendChild(zip);
+                a.appendChild(document.createTextNode(' '));
+            }
+            a.appendChild(document.createTextNode(c.name));
+
+            var name = document.createElement('div');
+            name.className = 'location-name';
+            name.appendChild(a);
+
+            var item = document.createElement('div');
+            item.className = 'item';
+            item.appendChild(name);
+            list.appendChild(item);
+        });
+    }
+
+    function suggest() {
+        var q = input.value.trim();
+        if (controller) {
+            controller.abort();
+            controller = null;
+        }
+        if (q.length < 2) {
+            clear();
+            return;
+        }
+        if (window.AbortController) {
+            controller = new AbortController();
+        }
+        fetch('/api/search?q=' + encodeURIComponent(q), {
+            signal: controller ? controller.signal : undefined
+        }).then(function (resp) {
+            return resp.ok ? resp.json() : [];
+        }).then(show).catch(function () {
+            // Aborted or failed; the form still works.
+        });
+    }
+
+    input.setAttribute('autocomplete', 'off');
+    input.addEventListener('input', function () {
+        clearTimeout(timer);
+        timer = setTimeout(suggest, debounce);
+    });
+})();
@@ -0,0 +1,213 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+// Plain-text rendering of the weather for terminals.
+package term
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"ricketyspace.net/peach/weather"
+)
+
+// Width of the report in columns.
+const width = 72
+
+// ANSI escape sequences.
+const (
+	reset  = "\033[0m"
+	bold   = "\033[1m"
+	red    = "\033[31m"
+	yellow = "\033[33m"
+	blue   = "\033[34m"
+	cyan   = "\033[36m"
+)
+
+// Writes a fixed-width text report of weather `w` to `out`. ANSI
+// colors are used when `color` is true.
+func Render(out io.Writer, w *weather.Weather, color bool) error {
+	r := &report{
+		out:   bufio.NewWriter(out),
+		color: color,
+	}
+
+	// Location.
+	r.line(r.paint(bold, w.Location))
+	r.line("")
+
+	// Now.
+	r.line(fmt.Sprintf("  %s  %s",
+		r.temperature(w.Now.Temperature, w.Now.TemperatureUnit),
+		w.Now.Forecast))
+	misc := fmt.Sprintf("  wind %s %s", w.Now.WindSpeed, w.Now.WindDirection)
+	if len(w.Now.WindGust) > 0 {
+		misc += fmt.Sprintf("   gusts %s", w.Now.WindGust)
+	}
+	if w.Now.Humidity > 0 {
+		misc += fmt.Sprintf("   humidity %d%%", w.Now.Humidity)
+	}
+	r.line(misc)
+	if len(w.Now.Pressure) > 0 || len(w.Now.Visibility) > 0 {
+		misc = " "
+		if len(w.Now.Pressure) > 0 {
+			misc += fmt.Sprintf(" pressure %s  ", w.Now.Pressure)
+		}
+		if len(w.Now.Visibility) > 0 {
+			misc += fmt.Sprintf(" visibility %s", w.Now.Visibility)
+		}
+		r.line(strings.TrimRight(misc, " "))
+	}
+	if w.Now.ObservedAt != nil {
+		r.line(r.paint(cyan, fmt.Sprintf("  observed %s at %s",
+			w.Now.ObservedAt.Format("15:04"), w.Now.Station)))
+	}
+	r.line("")
+
+	// Q2H timeline, in rows of up to 10 periods.
+	periods := w.Q2HTimeline.Periods
+	for len(periods) > 0 {
+		row := periods
+		if len(row) > 10 {
+			row = row[:10]
+		}
+		periods = periods[len(row):]
+		hours := "  "
+		temps := "  "
+		precip := "  "
+		rain := false
+		for _, p := range row {
+			hours += fmt.Sprintf("%7s", fmt.Sprintf("%dhrs", p.Hour))
+			t := fmt.Sprintf("%d%s", p.Temperature, p.TemperatureUnit)
+			temps += strings.Repeat(" ", 7-len(t)) +
+				r.temperature(p.Temperature, p.TemperatureUnit)
+			precip += fmt.Sprintf("%7s",
+				fmt.Sprintf("%d%%", p.PrecipitationChance))
+			rain = rain || p.PrecipitationChance > 0
+		}
+		r.line(hours)
+		r.line(temps)
+		if rain {
+			r.line(precip)
+		}
+		r.line("")
+	}
+
+	// Notices.
+	if w.AsOf != nil {
+		r.line("  " + r.paint(yellow, "* data as of "+
+			w.AsOf.Format("Jan 2 15:04")))
+	}
+	for _, n := range w.Notices {
+		r.line("  " + r.paint(yellow, "* "+n))
+	}
+	if w.AsOf != nil || len(w.Notices) > 0 {
+		r.line("")
+	}
+
+	// Alerts.
+	for _, a := range w.Alerts {
+		r.line("  " + r.paint(red+bold,
+			fmt.Sprintf("! %s (%s)", a.Event, a.Severity)))
+		for _, p := range a.Description {
+			r.wrap(p, "    ")
+			r.line("")
+		}
+		for _, p := range a.Instruction {
+			if len(p) == 0 {
+				continue
+			}
+			r.wrap(p, "    ")
+			r.line("")
+		}
+	}
+
+	// BiDaily timeline.
+	for _, p := range w.BiDailyTimeline.Periods {
+		t := fmt.Sprintf("%d%s", p.Temperature, p.TemperatureUnit)
+		r.line(fmt.Sprintf("  %s%s%s",
+			r.paint(bold, fmt.Sprintf("%-18s", p.Name)),
+			strings.Repeat(" ", 5-len(t)),
+			r.temperature(p.Temperature, p.TemperatureUnit)))
+		r.wrap(p.Forecast, "    ")
+		if p.Humidity > 0 {
+			grid := "    "
+			if p.PrecipitationChance > 0 {
+				grid += fmt.Sprintf("precipitation %d%%   ",
+					p.PrecipitationChance)
+			}
+			grid += fmt.Sprintf("humidity %d%%   dewpoint %d%s",
+				p.Humidity, p.Dewpoint, p.TemperatureUnit)
+			r.line(r.paint(cyan, grid))
+		}
+		r.line("")
+	}
+
+	// Source, if it is not weather.gov.
+	if len(w.Source) > 0 && w.Source != "weather.gov" {
+		r.line("  forecast from " + w.Source)
+		r.line("")
+	}
+
+	return r.out.Flush()
+}
+
+// Text report writer.
+type report struct {
+	out   *bufio.Writer
+	color bool
+}
+
+// Writes line `l`.
+func (r *report) line(l string) {
+	r.out.WriteString(l)
+	r.out.WriteString("\n")
+}
+
+// Writes `text` word wrapped to the report width with each line
+// prefixed by `indent`.
+func (r *report) wrap(text, indent string) {
+	l := indent
+	for _, word := range strings.Fields(text) {
+		if len(l) > len(indent) && len(l)+1+len(word) > width {
+			r.line(l)
+			l = indent
+		}
+		if len(l) > len(indent) {
+			l += " "
+		}
+		l += word
+	}
+	if len(l) > len(indent) {
+		r.line(l)
+	}
+}
+
+// Wraps `s` with the ANSI `code` if colors are enabled.
+func (r *report) paint(code, s string) string {
+	if !r.color {
+		return s
+	}
+	return code + s + reset
+}
+
+// Formats temperature `t`; the temperature is colored by how warm it
+// is if colors are enabled.
+func (r *report) temperature(t int, unit string) string {
+	f := t
+	if unit == "C" {
+		f = t*9/5 + 32
+	}
+	code := yellow
+	switch {
+	case f < 50:
+		code = blue
+	case f < 65:
+		code = cyan
+	case f >= 85:
+		code = red
+	}
+	return r.paint(code, fmt.Sprintf("%d%s", t, unit))
+}
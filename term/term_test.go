@@ -0,0 +1,154 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package term
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"ricketyspace.net/peach/weather"
+)
+
+func testWeather() *weather.Weather {
+	observedAt := time.Date(2022, 8, 7, 14, 53, 0, 0, time.UTC)
+	asOf := time.Date(2022, 8, 7, 13, 5, 0, 0, time.UTC)
+	return &weather.Weather{
+		Location: "tiffin, oh",
+		AsOf:     &asOf,
+		Now: weather.WeatherNow{
+			Temperature:     72,
+			TemperatureUnit: "F",
+			Forecast:        "Partly Sunny",
+			WindSpeed:       "10 mph",
+			WindDirection:   "SW",
+			Humidity:        65,
+			WindGust:        "22 mph",
+			Pressure:        "29.92 inHg",
+			Visibility:      "10 mi",
+			Station:         "KTDZ",
+			ObservedAt:      &observedAt,
+		},
+		Q2HTimeline: weather.WeatherTimeline{
+			Periods: []weather.WeatherPeriod{
+				{Hour: 12, Temperature: 72, TemperatureUnit: "F"},
+				{Hour: 14, Temperature: 75, TemperatureUnit: "F", PrecipitationChance: 20},
+				{Hour: 16, Temperature: 101, TemperatureUnit: "F"},
+			},
+		},
+		BiDailyTimeline: weather.WeatherTimeline{
+			Periods: []weather.WeatherPeriod{
+				{
+					Name:            "Tonight",
+					Forecast:        "Mostly clear, with a low around 62. Southwest wind around 5 mph. Patchy fog after 3am. Visibility may drop below a mile at times.",
+					Temperature:     62,
+					TemperatureUnit: "F",
+					Humidity:        90,
+					Dewpoint:        60,
+				},
+			},
+		},
+		Notices: []string{"alerts unavailable"},
+		Alerts: []weather.Alert{
+			{
+				Event:       "Heat Advisory",
+				Severity:    "Moderate",
+				Description: []string{"* WHAT...Heat index values of 108 to 112 expected."},
+				Instruction: []string{""},
+			},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	b := new(bytes.Buffer)
+	err := Render(b, testWeather(), false)
+	if err != nil {
+		t.Errorf("render: %v", err)
+		return
+	}
+	out := b.String()
+	if strings.Contains(out, "\033[") {
+		t.Errorf("render: output has ansi escapes: %q", out)
+		return
+	}
+	for _, s := range []string{
+		"tiffin, oh\n",
+		"  72F  Partly Sunny\n",
+		"  wind 10 mph SW   gusts 22 mph   humidity 65%\n",
+		"  pressure 29.92 inHg   visibility 10 mi\n",
+		"  observed 14:53 at KTDZ\n",
+		"    12hrs  14hrs  16hrs\n",
+		"      72F    75F   101F\n",
+		"  * data as of Aug 7 13:05\n",
+		"  * alerts unavailable\n",
+		"  ! Heat Advisory (Moderate)\n",
+		"       0%    20%     0%\n",
+		"  Tonight             62F\n",
+		"    humidity 90%   dewpoint 60F\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("render: %q not in:\n%s", s, out)
+			return
+		}
+	}
+	for i, l := range strings.Split(out, "\n") {
+		if len(l) > width {
+			t.Errorf("render: line %d longer than %d: %q", i, width, l)
+			return
+		}
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	b := new(bytes.Buffer)
+	err := Render(b, testWeather(), true)
+	if err != nil {
+		t.Errorf("render: %v", err)
+		return
+	}
+	out := b.String()
+	if !strings.Contains(out, bold+"tiffin, oh"+reset) {
+		t.Errorf("render: location not bold: %q", out)
+		return
+	}
+	if !strings.Contains(out, red+"101F"+reset) {
+		t.Errorf("render: 101F not red: %q", out)
+		return
+	}
+}
+
+func TestRenderLongTimeline(t *testing.T) {
+	w := testWeather()
+	w.Q2HTimeline.Periods = nil
+	for h := 0; h < 24; h++ {
+		w.Q2HTimeline.Periods = append(w.Q2HTimeline.Periods,
+			weather.WeatherPeriod{Hour: h, Temperature: 60 + h, TemperatureUnit: "F"})
+	}
+	b := new(bytes.Buffer)
+	err := Render(b, w, false)
+	if err != nil {
+		t.Errorf("render: %v", err)
+		return
+	}
+	out := b.String()
+	for _, s := range []string{
+		"     0hrs   1hrs   2hrs   3hrs   4hrs   5hrs   6hrs   7hrs   8hrs   9hrs\n",
+		"    10hrs  11hrs  12hrs  13hrs  14hrs  15hrs  16hrs  17hrs  18hrs  19hrs\n",
+		"    20hrs  21hrs  22hrs  23hrs\n",
+		"      80F    81F    82F    83F\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("render: %q not in:\n%s", s, out)
+			return
+		}
+	}
+	for i, l := range strings.Split(out, "\n") {
+		if len(l) > width {
+			t.Errorf("render: line %d longer than %d: %q", i, width, l)
+			return
+		}
+	}
+}
@@ -6,51 +6,288 @@ package time
 
 import (
 	"fmt"
-	"regexp"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// ISO 8601 Duration regex for matching duration in P18DT3H4M60S format.
-var durationRegex = regexp.MustCompile(`P(([0-9]{1,})?D)?T(([0-9]{1,2})?H)?(([0-9]{1,2})?M)?(([0-9]{1,2}?)S)?`)
+// Nominal lengths of the calendar components of an ISO 8601
+// duration.
+const (
+	day   = 24 * time.Hour
+	week  = 7 * day
+	month = 30 * day
+	year  = 365 * day
+)
 
-// Converts ISO 8601 duration[1] to time.Duration
+// ISO 8601 duration[1] in PnYnMnWnDTnHnMnS format.
 //
-// Recognizes durations in this format: P18DT3H4M60S
+// The components are kept apart since the length of a year, a month
+// and a day depends on when the duration starts.
 //
 // [1]: https://en.wikipedia.org/wiki/ISO_8601#Durations
-func Duration(duration string) (time.Duration, error) {
-	m := durationRegex.FindStringSubmatch(duration)
-	if m == nil || len(m) == 0 {
-		return 0, fmt.Errorf("duration invalid: %v", duration)
+type Period struct {
+	Years   float64
+	Months  float64
+	Weeks   float64
+	Days    float64
+	Hours   float64
+	Minutes float64
+	Seconds float64
+}
+
+// Parses ISO 8601 duration `duration` in PnYnMnWnDTnHnMnS format.
+//
+// Every component is optional, but there must be at least one and
+// they must be in order. Any number of digits is allowed for each
+// component. The last component may have a decimal fraction, with
+// either a '.' or a ',' as the decimal mark; for instance, PT0.5S or
+// P1,5D.
+func ParsePeriod(duration string) (Period, error) {
+	p := Period{}
+	fail := func(format string, a ...any) (Period, error) {
+		return Period{}, fmt.Errorf("duration invalid: %q: %s",
+			duration, fmt.Sprintf(format, a...))
 	}
-	days, err := strconv.Atoi(m[2])
-	if err != nil {
-		days = 0
+
+	s := duration
+	if !strings.HasPrefix(s, "P") {
+		return fail("must start with P")
 	}
-	hours, err := strconv.Atoi(m[4])
-	if err != nil {
-		hours = 0
+	s = s[1:]
+	if len(s) == 0 {
+		return fail("no components")
 	}
-	mins, err := strconv.Atoi(m[6])
-	if err != nil {
-		mins = 0
+
+	// Designators and the components they set, in order.
+	date := []struct {
+		designator byte
+		value      *float64
+	}{
+		{'Y', &p.Years}, {'M', &p.Months}, {'W', &p.Weeks}, {'D', &p.Days},
+	}
+	clock := []struct {
+		designator byte
+		value      *float64
+	}{
+		{'H', &p.Hours}, {'M', &p.Minutes}, {'S', &p.Seconds},
+	}
+
+	components := 0
+	fraction := false
+	inTime := false
+	next := 0 // Index of the next allowed designator.
+	for len(s) > 0 {
+		if s[0] == 'T' {
+			if inTime {
+				return fail("more than one T")
+			}
+			if len(s) == 1 {
+				return fail("no time components after T")
+			}
+			inTime = true
+			next = 0
+			s = s[1:]
+			continue
+		}
+
+		// Number.
+		i := 0
+		for i < len(s) && (s[i] >= '0' && s[i] <= '9' ||
+			s[i] == '.' || s[i] == ',') {
+			i++
+		}
+		if i == 0 {
+			return fail("expected a number at %q", s)
+		}
+		if i == len(s) {
+			return fail("number %q has no designator", s)
+		}
+		if fraction {
+			return fail("only the last component may have a fraction")
+		}
+		num := strings.Replace(s[:i], ",", ".", 1)
+		if strings.HasPrefix(num, ".") || strings.HasSuffix(num, ".") ||
+			strings.Count(num, ".") > 1 || strings.Contains(num, ",") {
+			return fail("number %q is malformed", s[:i])
+		}
+		v, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return fail("number %q is malformed", s[:i])
+		}
+		fraction = strings.Contains(num, ".")
+
+		// Designator.
+		designators := date
+		if inTime {
+			designators = clock
+		}
+		d := s[i]
+		found := false
+		for j := next; j < len(designators); j++ {
+			if designators[j].designator == d {
+				*designators[j].value = v
+				next = j + 1
+				found = true
+				break
+			}
+		}
+		if !found {
+			part := "date"
+			if inTime {
+				part = "time"
+			}
+			return fail("unexpected designator %q in %s part", d, part)
+		}
+		components++
+		s = s[i+1:]
 	}
-	secs, err := strconv.Atoi(m[8])
+	if components == 0 {
+		return fail("no components")
+	}
+	return p, nil
+}
+
+// Returns the nominal length of the period. A year is taken to be
+// 365 days, a month 30 days and a day 24 hours.
+func (p Period) Duration() (time.Duration, error) {
+	ns := p.Years*float64(year) + p.Months*float64(month) +
+		p.Weeks*float64(week) + p.Days*float64(day) +
+		p.Hours*float64(time.Hour) + p.Minutes*float64(time.Minute) +
+		p.Seconds*float64(time.Second)
+	if ns >= math.MaxInt64 {
+		return 0, fmt.Errorf("duration too long: %v", p)
+	}
+	return time.Duration(math.Round(ns)), nil
+}
+
+// Returns time `t` plus the period. Whole years, months, weeks and
+// days are added on the calendar in `t`'s location; fractions of them
+// are added using their nominal length.
+func (p Period) AddTo(t time.Time) time.Time {
+	y, fy := math.Modf(p.Years)
+	m, fm := math.Modf(p.Months)
+	w, fw := math.Modf(p.Weeks)
+	d, fd := math.Modf(p.Days)
+	t = t.AddDate(int(y), int(m), int(w)*7+int(d))
+
+	ns := fy*float64(year) + fm*float64(month) + fw*float64(week) +
+		fd*float64(day) + p.Hours*float64(time.Hour) +
+		p.Minutes*float64(time.Minute) + p.Seconds*float64(time.Second)
+	return t.Add(time.Duration(math.Round(ns)))
+}
+
+// Returns time `t` minus the period.
+func (p Period) SubFrom(t time.Time) time.Time {
+	return Period{
+		Years:   -p.Years,
+		Months:  -p.Months,
+		Weeks:   -p.Weeks,
+		Days:    -p.Days,
+		Hours:   -p.Hours,
+		Minutes: -p.Minutes,
+		Seconds: -p.Seconds,
+	}.AddTo(t)
+}
+
+// Converts ISO 8601 duration to time.Duration
+//
+// Recognizes durations in this format: P1Y2M3W4DT5H6M7.5S. See
+// ParsePeriod for details and Period.Duration for how years, months
+// and days are converted.
+func Duration(duration string) (time.Duration, error) {
+	p, err := ParsePeriod(duration)
 	if err != nil {
-		secs = 0
+		return 0, err
 	}
+	return p.Duration()
+}
 
-	// Add 'em all together.
-	secs += days * 86400
-	secs += hours * 3600
-	secs += mins * 60
+// Parses ISO 8601 time interval[1] `t` into its start and end times.
+//
+// `t` may be in any of these formats:
+//
+//	2022-08-07T02:00:00+00:00/2022-08-07T05:00:00+00:00 (start/end)
+//	2022-08-07T02:00:00+00:00/PT3H                      (start/duration)
+//	PT3H/2022-08-07T05:00:00+00:00                      (duration/end)
+//
+// The times must be in RFC 3339 format.
+//
+// [1]: https://en.wikipedia.org/wiki/ISO_8601#Time_intervals
+func Interval(t string) (time.Time, time.Time, error) {
+	var t1, t2 time.Time
+	fail := func(format string, a ...any) (time.Time, time.Time, error) {
+		return time.Time{}, time.Time{}, fmt.Errorf(
+			"interval invalid: %q: %s", t, fmt.Sprintf(format, a...))
+	}
 
-	// Convert seconds to time.Duration.
-	d, err := time.ParseDuration(fmt.Sprintf("%ds", secs))
+	parts := strings.Split(t, "/")
+	if len(parts) != 2 {
+		return fail("must have exactly one '/'")
+	}
+	startIsPeriod := strings.HasPrefix(parts[0], "P")
+	endIsPeriod := strings.HasPrefix(parts[1], "P")
+	switch {
+	case startIsPeriod && endIsPeriod:
+		return fail("both parts are durations")
+	case endIsPeriod: // start/duration
+		start, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			return fail("start: %v", err)
+		}
+		p, err := ParsePeriod(parts[1])
+		if err != nil {
+			return fail("%v", err)
+		}
+		t1, t2 = start, p.AddTo(start)
+	case startIsPeriod: // duration/end
+		p, err := ParsePeriod(parts[0])
+		if err != nil {
+			return fail("%v", err)
+		}
+		end, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			return fail("end: %v", err)
+		}
+		t1, t2 = p.SubFrom(end), end
+	default: // start/end
+		start, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			return fail("start: %v", err)
+		}
+		end, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			return fail("end: %v", err)
+		}
+		t1, t2 = start, end
+	}
+	if t2.Before(t1) {
+		return fail("end is before start")
+	}
+	return t1, t2, nil
+}
 
-	return d, nil
+// Expands ISO 8601 time interval `t` into the hours that it spans.
+//
+// `t` must be in 2022-08-07T02:00:00+00:00/PT3H format
+//
+// Returns the start time, in UTC, of every hour that overlaps with
+// the interval; for 2022-08-07T02:00:00+00:00/PT3H that is 02:00,
+// 03:00 and 04:00 on 2022-08-07.
+func Hours(t string) ([]time.Time, error) {
+	t1, t2, err := Interval(t)
+	if err != nil {
+		return nil, err
+	}
+	if !t1.Before(t2) {
+		return nil, fmt.Errorf("time invalid: empty interval: %s", t)
+	}
+	hours := []time.Time{}
+	for h := t1.UTC().Truncate(time.Hour); h.Before(t2); h = h.Add(time.Hour) {
+		hours = append(hours, h)
+	}
+	return hours, nil
 }
 
 // Checks if the given ISO 8601 time is within the current time
@@ -61,21 +298,10 @@ func Duration(duration string) (time.Duration, error) {
 // Returns true if the time `t` is the current time period; false
 // otherwise.
 func IsCurrent(t string) (bool, error) {
-	parts := strings.Split(t, "/")
-	if len(parts) != 2 {
-		return false, fmt.Errorf("time invalid")
-	}
-
-	// Parse time `t` into time intervals t1 and t2.
-	t1, err := time.Parse(time.RFC3339, parts[0])
-	if err != nil {
-		return false, fmt.Errorf("time invalid: %s", err)
-	}
-	d, err := Duration(parts[1])
+	t1, t2, err := Interval(t)
 	if err != nil {
-		return false, fmt.Errorf("time invalid: %s", err)
+		return false, err
 	}
-	t2 := t1.Add(d)
 
 	// Time `t` is in the current time period if current time is
 	// within the interval t1 and t2.
@@ -5,6 +5,7 @@ package time
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -111,3 +112,230 @@ func TestIsCurrent(t *testing.T) {
 	}
 
 }
+
+func TestInterval(t *testing.T) {
+	t1, t2, err := Interval("2022-08-07T02:00:00+00:00/PT3H")
+	if err != nil {
+		t.Errorf("interval failed: %v", err)
+		return
+	}
+	if t1.Format(time.RFC3339) != "2022-08-07T02:00:00Z" {
+		t.Errorf("interval start: %v", t1)
+		return
+	}
+	if t2.Format(time.RFC3339) != "2022-08-07T05:00:00Z" {
+		t.Errorf("interval end: %v", t2)
+		return
+	}
+
+	_, _, err = Interval("2022-08-07T02:00:00+00:00")
+	if err == nil {
+		t.Errorf("interval did not fail")
+		return
+	}
+}
+
+func TestHours(t *testing.T) {
+	hours, err := Hours("2022-08-07T02:00:00-04:00/PT3H")
+	if err != nil {
+		t.Errorf("hours failed: %v", err)
+		return
+	}
+	expected := []string{
+		"2022-08-07T06:00:00Z",
+		"2022-08-07T07:00:00Z",
+		"2022-08-07T08:00:00Z",
+	}
+	if len(hours) != len(expected) {
+		t.Errorf("hours: %v", hours)
+		return
+	}
+	for i, h := range hours {
+		if h.Format(time.RFC3339) != expected[i] {
+			t.Errorf("hours: %d: %v != %v", i, h, expected[i])
+			return
+		}
+	}
+
+	// Interval that does not start on the hour.
+	hours, err = Hours("2022-08-07T02:30:00+00:00/PT1H")
+	if err != nil {
+		t.Errorf("hours failed: %v", err)
+		return
+	}
+	if len(hours) != 2 || hours[0].Format(time.RFC3339) != "2022-08-07T02:00:00Z" {
+		t.Errorf("hours: %v", hours)
+		return
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		duration string
+		seconds  float64
+	}{
+		{"P1Y", 365 * 86400},
+		{"P2M", 60 * 86400},
+		{"P3W", 21 * 86400},
+		{"P1Y2M3W4DT5H6M7S", 365*86400 + 60*86400 + 21*86400 + 4*86400 + 5*3600 + 6*60 + 7},
+		{"PT100H", 360000},
+		{"PT240M", 14400},
+		{"PT3600S", 3600},
+		{"PT0.5S", 0.5},
+		{"PT1,25S", 1.25},
+		{"P1.5D", 129600},
+		{"PT1H0.5M", 3630},
+		{"P0D", 0},
+		{"PT0.000000001S", 0.000000001},
+	}
+	for _, test := range tests {
+		d, err := Duration(test.duration)
+		if err != nil {
+			t.Errorf("%s: failed: %v", test.duration, err)
+			return
+		}
+		if d.Seconds() != test.seconds {
+			t.Errorf("%s: duration in seconds incorrect: %v != %v",
+				test.duration, d.Seconds(), test.seconds)
+			return
+		}
+	}
+}
+
+func TestDurationInvalid(t *testing.T) {
+	tests := []struct {
+		duration string
+		err      string
+	}{
+		{"", `duration invalid: "": must start with P`},
+		{"T1H", `duration invalid: "T1H": must start with P`},
+		{"P", `duration invalid: "P": no components`},
+		{"PT", `duration invalid: "PT": no time components after T`},
+		{"P1DT", `duration invalid: "P1DT": no time components after T`},
+		{"PT1HT2M", `duration invalid: "PT1HT2M": more than one T`},
+		{"P1H", `duration invalid: "P1H": unexpected designator 'H' in date part`},
+		{"PT1D", `duration invalid: "PT1D": unexpected designator 'D' in time part`},
+		{"P1D1Y", `duration invalid: "P1D1Y": unexpected designator 'Y' in date part`},
+		{"PT1M1M", `duration invalid: "PT1M1M": unexpected designator 'M' in time part`},
+		{"P1.5DT1H", `duration invalid: "P1.5DT1H": only the last component may have a fraction`},
+		{"PT.5S", `duration invalid: "PT.5S": number ".5" is malformed`},
+		{"PT5.S", `duration invalid: "PT5.S": number "5." is malformed`},
+		{"PT1.2.3S", `duration invalid: "PT1.2.3S": number "1.2.3" is malformed`},
+		{"PT5", `duration invalid: "PT5": number "5" has no designator`},
+		{"PTxH", `duration invalid: "PTxH": expected a number at "xH"`},
+		{"P-1D", `duration invalid: "P-1D": expected a number at "-1D"`},
+		{"PT1H ", `duration invalid: "PT1H ": expected a number at " "`},
+	}
+	for _, test := range tests {
+		_, err := Duration(test.duration)
+		if err == nil {
+			t.Errorf("%s: did not fail", test.duration)
+			return
+		}
+		if err.Error() != test.err {
+			t.Errorf("%s: error: %v != %v", test.duration, err, test.err)
+			return
+		}
+	}
+}
+
+func TestPeriodAddTo(t *testing.T) {
+	p, err := ParsePeriod("P1M")
+	if err != nil {
+		t.Errorf("parse: %v", err)
+		return
+	}
+	t1 := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	if t2 := p.AddTo(t1); !t2.Equal(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("add to: %v", t2)
+		return
+	}
+	if t2 := p.SubFrom(t1); !t2.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("sub from: %v", t2)
+		return
+	}
+
+	p, err = ParsePeriod("P1Y1W1DT1H1M1.5S")
+	if err != nil {
+		t.Errorf("parse: %v", err)
+		return
+	}
+	t2 := p.AddTo(t1)
+	e := time.Date(2023, 2, 9, 1, 1, 1, 500000000, time.UTC)
+	if !t2.Equal(e) {
+		t.Errorf("add to: %v != %v", t2, e)
+		return
+	}
+}
+
+func TestIntervalForms(t *testing.T) {
+	tests := []struct {
+		interval string
+		start    string
+		end      string
+	}{
+		{
+			"2022-08-07T02:00:00+00:00/2022-08-07T05:00:00+00:00",
+			"2022-08-07T02:00:00Z", "2022-08-07T05:00:00Z",
+		},
+		{
+			"2022-08-07T02:00:00+00:00/PT3H",
+			"2022-08-07T02:00:00Z", "2022-08-07T05:00:00Z",
+		},
+		{
+			"PT3H/2022-08-07T05:00:00+00:00",
+			"2022-08-07T02:00:00Z", "2022-08-07T05:00:00Z",
+		},
+		{
+			"2022-01-31T00:00:00Z/P1M",
+			"2022-01-31T00:00:00Z", "2022-03-03T00:00:00Z",
+		},
+		{
+			"P1DT12H/2022-08-07T12:00:00Z",
+			"2022-08-06T00:00:00Z", "2022-08-07T12:00:00Z",
+		},
+		{
+			"2022-08-07T02:00:00.5Z/PT0.5S",
+			"2022-08-07T02:00:00.5Z", "2022-08-07T02:00:01Z",
+		},
+	}
+	for _, test := range tests {
+		t1, t2, err := Interval(test.interval)
+		if err != nil {
+			t.Errorf("%s: failed: %v", test.interval, err)
+			return
+		}
+		if t1.UTC().Format(time.RFC3339Nano) != test.start {
+			t.Errorf("%s: start: %v", test.interval, t1)
+			return
+		}
+		if t2.UTC().Format(time.RFC3339Nano) != test.end {
+			t.Errorf("%s: end: %v", test.interval, t2)
+			return
+		}
+	}
+}
+
+func TestIntervalInvalid(t *testing.T) {
+	tests := []struct {
+		interval string
+		err      string
+	}{
+		{"2022-08-07T02:00:00Z", `interval invalid: "2022-08-07T02:00:00Z": must have exactly one '/'`},
+		{"PT1H/PT2H", `interval invalid: "PT1H/PT2H": both parts are durations`},
+		{"2022-08-07/PT1H", `interval invalid: "2022-08-07/PT1H": start: parsing time "2022-08-07"`},
+		{"2022-08-07T02:00:00Z/PT", `interval invalid: "2022-08-07T02:00:00Z/PT": duration invalid: "PT": no time components after T`},
+		{"2022-08-07T05:00:00Z/2022-08-07T02:00:00Z", `interval invalid: "2022-08-07T05:00:00Z/2022-08-07T02:00:00Z": end is before start`},
+	}
+	for _, test := range tests {
+		_, _, err := Interval(test.interval)
+		if err == nil {
+			t.Errorf("%s: did not fail", test.interval)
+			return
+		}
+		if !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%s: error: %v != %v", test.interval, err, test.err)
+			return
+		}
+	}
+}
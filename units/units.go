@@ -0,0 +1,184 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+// Systems of units and conversions between their units.
+package units
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A system of units.
+type System string
+
+const (
+	US System = "us" // °F, mph, inHg, mi and in.
+	SI System = "si" // °C, km/h, hPa, km and mm.
+	UK System = "uk" // °C, mph, hPa, mi and mm.
+)
+
+// The units of a System.
+type Units struct {
+	Temperature   string // F or C.
+	Speed         string // mph or km/h.
+	Pressure      string // inHg or hPa.
+	Distance      string // mi or km.
+	Precipitation string // in or mm.
+}
+
+// Units of the systems.
+var systems = map[System]Units{
+	US: {"F", "mph", "inHg", "mi", "in"},
+	SI: {"C", "km/h", "hPa", "km", "mm"},
+	UK: {"C", "mph", "hPa", "mi", "mm"},
+}
+
+// Conversions between units, by their from and to units.
+var conversions = map[[2]string]func(float64) float64{
+	{"F", "C"}:      func(v float64) float64 { return (v - 32) * 5 / 9 },
+	{"C", "F"}:      func(v float64) float64 { return v*9/5 + 32 },
+	{"mph", "km/h"}: func(v float64) float64 { return v * 1.609344 },
+	{"km/h", "mph"}: func(v float64) float64 { return v / 1.609344 },
+	{"inHg", "hPa"}: func(v float64) float64 { return v * 33.86389 },
+	{"hPa", "inHg"}: func(v float64) float64 { return v / 33.86389 },
+	{"mi", "km"}:    func(v float64) float64 { return v * 1.609344 },
+	{"km", "mi"}:    func(v float64) float64 { return v / 1.609344 },
+	{"in", "mm"}:    func(v float64) float64 { return v * 25.4 },
+	{"mm", "in"}:    func(v float64) float64 { return v / 25.4 },
+}
+
+// Number of decimals that values in each unit are formatted with.
+var decimals = map[string]int{
+	"inHg": 2,
+	"mi":   1,
+	"km":   1,
+	"in":   2,
+}
+
+// Measurement regex; for instance, 10 mph, 5 to 10 mph or 30.01 inHg.
+var measurementRegex = regexp.MustCompile(`^(-?[0-9]+(?:\.[0-9]+)?)(?: to (-?[0-9]+(?:\.[0-9]+)?))? ?([A-Za-z/]+)$`)
+
+// Returns the system named `s`; US if `s` is empty.
+func ParseSystem(s string) (System, error) {
+	if len(s) == 0 {
+		return US, nil
+	}
+	sys := System(strings.ToLower(s))
+	if _, ok := systems[sys]; !ok {
+		return US, fmt.Errorf("units %q are not one of us, si or uk", s)
+	}
+	return sys, nil
+}
+
+// Returns the units of system `s`.
+func (s System) Units() Units {
+	if u, ok := systems[s]; ok {
+		return u
+	}
+	return systems[US]
+}
+
+// Returns value `v` in unit `from` converted to unit `to`. Returns an
+// error if there is no conversion between the units.
+func Convert(v float64, from, to string) (float64, error) {
+	if from == to {
+		return v, nil
+	}
+	c, ok := conversions[[2]string{from, to}]
+	if !ok {
+		return v, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	return c(v), nil
+}
+
+// A measurement, or a range of them; for instance, 5 to 10 mph.
+type Measurement struct {
+	Min  float64
+	Max  float64 // Same as Min if the measurement is not a range.
+	Unit string
+}
+
+// Parses measurement `s`; for instance, 10 mph, 5 to 10 mph, 16 km/h
+// or 30.01 inHg.
+func Parse(s string) (Measurement, error) {
+	m := measurementRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Measurement{}, fmt.Errorf("measurement invalid: %q", s)
+	}
+	min, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return Measurement{}, err
+	}
+	max := min
+	if len(m[2]) > 0 {
+		max, err = strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			return Measurement{}, err
+		}
+	}
+	return Measurement{min, max, m[3]}, nil
+}
+
+// Returns the measurement converted to unit `unit`.
+func (m Measurement) In(unit string) (Measurement, error) {
+	min, err := Convert(m.Min, m.Unit, unit)
+	if err != nil {
+		return m, err
+	}
+	max, _ := Convert(m.Max, m.Unit, unit)
+	return Measurement{min, max, unit}, nil
+}
+
+// Formats the measurement; for instance, 5 to 10 mph.
+func (m Measurement) String() string {
+	min := Format(m.Min, m.Unit)
+	max := Format(m.Max, m.Unit)
+	if min == max {
+		return fmt.Sprintf("%s %s", min, m.Unit)
+	}
+	return fmt.Sprintf("%s to %s %s", min, max, m.Unit)
+}
+
+// Formats value `v` in unit `unit`, rounded to the decimals that the
+// unit is shown with.
+func Format(v float64, unit string) string {
+	d := decimals[unit]
+	p := math.Pow(10, float64(d))
+	v = math.Round(v*p) / p
+	if v == 0 {
+		v = 0 // Not -0.
+	}
+	if unit == "inHg" {
+		return strconv.FormatFloat(v, 'f', d, 64)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Returns measurement `s` converted to unit `unit`; for instance,
+// 8 to 16 km/h for 5 to 10 mph. Returns `s` as is if it is not a
+// measurement that can be converted.
+func ConvertString(s, unit string) string {
+	m, err := Parse(s)
+	if err != nil {
+		return s
+	}
+	if m.Unit == unit {
+		return s
+	}
+	c, err := m.In(unit)
+	if err != nil {
+		return s
+	}
+	return c.String()
+}
+
+// Returns temperature `t` in unit `from` converted to unit `to`,
+// rounded to a whole degree.
+func Temperature(t int, from, to string) int {
+	v, _ := Convert(float64(t), from, to)
+	return int(math.Round(v))
+}
@@ -0,0 +1,125 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSystem(t *testing.T) {
+	for s, e := range map[string]System{
+		"":   US,
+		"us": US,
+		"si": SI,
+		"SI": SI,
+		"uk": UK,
+	} {
+		sys, err := ParseSystem(s)
+		if err != nil || sys != e {
+			t.Errorf("parse system: %q: %v %v", s, sys, err)
+			return
+		}
+	}
+	if _, err := ParseSystem("metric"); err == nil {
+		t.Errorf("parse system: metric: no error")
+		return
+	}
+	if SI.Units().Speed != "km/h" || UK.Units().Speed != "mph" ||
+		UK.Units().Temperature != "C" || System("").Units() != US.Units() {
+		t.Errorf("units: %v %v", SI.Units(), UK.Units())
+		return
+	}
+}
+
+func TestConvert(t *testing.T) {
+	for _, c := range []struct {
+		v        float64
+		from, to string
+		e        float64
+	}{
+		{212, "F", "C", 100},
+		{-40, "C", "F", -40},
+		{10, "mph", "km/h", 16.09344},
+		{30, "inHg", "hPa", 1015.9167},
+		{1013.25, "hPa", "inHg", 29.9213},
+		{10, "mi", "km", 16.09344},
+		{1, "in", "mm", 25.4},
+		{7, "mph", "mph", 7},
+	} {
+		v, err := Convert(c.v, c.from, c.to)
+		if err != nil || math.Abs(v-c.e) > 0.0001 {
+			t.Errorf("convert: %v %s to %s: %v %v", c.v, c.from, c.to, v, err)
+			return
+		}
+	}
+	if _, err := Convert(10, "mph", "hPa"); err == nil {
+		t.Errorf("convert: mph to hPa: no error")
+		return
+	}
+}
+
+func TestParse(t *testing.T) {
+	m, err := Parse("5 to 10 mph")
+	if err != nil || m.Min != 5 || m.Max != 10 || m.Unit != "mph" {
+		t.Errorf("parse: %v %v", m, err)
+		return
+	}
+	m, err = Parse("30.01 inHg")
+	if err != nil || m.Min != 30.01 || m.Max != 30.01 || m.Unit != "inHg" {
+		t.Errorf("parse: %v %v", m, err)
+		return
+	}
+	m, err = Parse("16 km/h")
+	if err != nil || m.Min != 16 || m.Unit != "km/h" {
+		t.Errorf("parse: %v %v", m, err)
+		return
+	}
+	for _, s := range []string{"", "mph", "calm", "5 to mph", "10"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("parse: %q: no error", s)
+			return
+		}
+	}
+}
+
+func TestConvertString(t *testing.T) {
+	for _, c := range [][3]string{
+		{"5 to 10 mph", "km/h", "8 to 16 km/h"},
+		{"10 mph", "km/h", "16 km/h"},
+		{"0 mph", "km/h", "0 km/h"},
+		{"16 km/h", "mph", "10 mph"},
+		{"30.01 inHg", "hPa", "1016 hPa"},
+		{"1016 hPa", "inHg", "30.00 inHg"},
+		{"10 mi", "km", "16.1 km"},
+		{"0.25 in", "mm", "6 mm"},
+		{"10 mph", "mph", "10 mph"},
+		{"10 mph", "hPa", "10 mph"},
+		{"calm", "km/h", "calm"},
+	} {
+		if s := ConvertString(c[0], c[1]); s != c[2] {
+			t.Errorf("convert string: %q to %s: %q", c[0], c[1], s)
+			return
+		}
+	}
+}
+
+func TestTemperature(t *testing.T) {
+	if c := Temperature(75, "F", "C"); c != 24 {
+		t.Errorf("temperature: %v", c)
+		return
+	}
+	if c := Temperature(31, "F", "C"); c != -1 {
+		t.Errorf("temperature: %v", c)
+		return
+	}
+	if f := Temperature(24, "C", "F"); f != 75 {
+		t.Errorf("temperature: %v", f)
+		return
+	}
+	if f := Temperature(75, "F", "F"); f != 75 {
+		t.Errorf("temperature: %v", f)
+		return
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package weather
+
+// A day in the daily summary, made of its day and night periods.
+type WeatherDay struct {
+	Name string `json:"name"`
+
+	// High is nil when the day period is over and Low is nil when
+	// the night period is not in the forecast.
+	High            *int   `json:"high"`
+	Low             *int   `json:"low"`
+	TemperatureUnit string `json:"temperatureUnit"`
+
+	// Highest chance of precipitation in the day and the night.
+	PrecipitationChance int `json:"precipitationChance"`
+
+	// Condition of the period, day or night, with the more severe
+	// weather; for instance, Chance Showers And Thunderstorms.
+	Condition string `json:"condition"`
+	Icon      string `json:"icon"` // See icons.
+}
+
+// Pairs the day and night periods in `periods` into days. A night
+// period that is not after a day period, like Tonight, is a day of its
+// own.
+func daily(periods []WeatherPeriod) []WeatherDay {
+	days := []WeatherDay{}
+	for i := 0; i < len(periods); i++ {
+		p := periods[i]
+		d := WeatherDay{
+			Name:                p.Name,
+			TemperatureUnit:     p.TemperatureUnit,
+			PrecipitationChance: p.PrecipitationChance,
+			Condition:           p.Summary,
+			Icon:                p.Icon,
+		}
+		t := p.Temperature
+		if !p.Daytime {
+			d.Low = &t
+			days = append(days, d)
+			continue
+		}
+		d.High = &t
+		if i+1 < len(periods) && !periods[i+1].Daytime {
+			i += 1
+			n := periods[i]
+			low := n.Temperature
+			d.Low = &low
+			if n.PrecipitationChance > d.PrecipitationChance {
+				d.PrecipitationChance = n.PrecipitationChance
+			}
+			if severity(n.Icon) > severity(p.Icon) {
+				d.Condition = n.Summary
+				d.Icon = variant(base(n.Icon), true)
+			}
+		}
+		days = append(days, d)
+	}
+	return days
+}
+
+// Returns the periods of the first `days` days in `periods`, with the
+// periods paired into days as daily pairs them; for instance, Tonight
+// is a day and Monday and Monday Night are a day.
+func dayPeriods(periods []WeatherPeriod, days int) []WeatherPeriod {
+	n := 0
+	for i := 0; i < len(periods); i++ {
+		if n == days {
+			return periods[:i]
+		}
+		n += 1
+		if periods[i].Daytime && i+1 < len(periods) && !periods[i+1].Daytime {
+			i += 1
+		}
+	}
+	return periods
+}
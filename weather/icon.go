@@ -0,0 +1,156 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package weather
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Condition icons, from the least to the most severe. The icons are at
+// /static/icons/{icon}.svg; clear and partly-cloudy have -day and
+// -night variants.
+var icons = []string{
+	"clear",
+	"partly-cloudy",
+	"cloudy",
+	"wind",
+	"fog",
+	"rain",
+	"snow",
+	"sleet",
+	"thunderstorm",
+}
+
+// Icons of the conditions in NWS icon links; for instance, tsra_sct
+// in https://api.weather.gov/icons/land/day/tsra_sct,40?size=medium.
+var nwsIcons = map[string]string{
+	"skc":             "clear",
+	"few":             "clear",
+	"hot":             "clear",
+	"cold":            "clear",
+	"sct":             "partly-cloudy",
+	"bkn":             "cloudy",
+	"ovc":             "cloudy",
+	"wind_skc":        "wind",
+	"wind_few":        "wind",
+	"wind_sct":        "wind",
+	"wind_bkn":        "wind",
+	"wind_ovc":        "wind",
+	"fog":             "fog",
+	"haze":            "fog",
+	"smoke":           "fog",
+	"dust":            "fog",
+	"rain":            "rain",
+	"rain_showers":    "rain",
+	"rain_showers_hi": "rain",
+	"snow":            "snow",
+	"blizzard":        "snow",
+	"rain_snow":       "sleet",
+	"rain_sleet":      "sleet",
+	"snow_sleet":      "sleet",
+	"sleet":           "sleet",
+	"fzra":            "sleet",
+	"rain_fzra":       "sleet",
+	"snow_fzra":       "sleet",
+	"tsra":            "thunderstorm",
+	"tsra_sct":        "thunderstorm",
+	"tsra_hi":         "thunderstorm",
+	"tornado":         "thunderstorm",
+	"hurricane":       "thunderstorm",
+	"tropical_storm":  "thunderstorm",
+}
+
+// Keywords in forecasts and their icons, in the order that they are
+// looked for.
+var iconKeywords = [][2]string{
+	{"thunder", "thunderstorm"},
+	{"freezing fog", "fog"},
+	{"rain and snow", "sleet"},
+	{"freezing", "sleet"},
+	{"sleet", "sleet"},
+	{"snow", "snow"},
+	{"flurr", "snow"},
+	{"blizzard", "snow"},
+	{"rain", "rain"},
+	{"shower", "rain"},
+	{"drizzle", "rain"},
+	{"fog", "fog"},
+	{"haze", "fog"},
+	{"smoke", "fog"},
+	{"dust", "fog"},
+	{"wind", "wind"},
+	{"breezy", "wind"},
+	{"blustery", "wind"},
+	{"partly", "partly-cloudy"},
+	{"mostly sunny", "partly-cloudy"},
+	{"mostly clear", "partly-cloudy"},
+	{"cloudy", "cloudy"},
+	{"overcast", "cloudy"},
+}
+
+// Returns the icon of a period from its NWS icon link `link`; from
+// its short forecast `forecast` if the link is empty or not known.
+func icon(link, forecast string, day bool) string {
+	if i, d, ok := nwsIcon(link); ok {
+		return variant(i, d)
+	}
+	forecast = strings.ToLower(forecast)
+	for _, k := range iconKeywords {
+		if strings.Contains(forecast, k[0]) {
+			return variant(k[1], day)
+		}
+	}
+	return variant("clear", day)
+}
+
+// Returns the most severe icon of the conditions in NWS icon link
+// `link` and whether the link is for a day period.
+func nwsIcon(link string) (string, bool, bool) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", false, false
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		if s != "day" && s != "night" {
+			continue
+		}
+		icon := ""
+		for _, c := range segments[i+1:] {
+			c, _, _ = strings.Cut(c, ",")
+			if ci, ok := nwsIcons[c]; ok && severity(ci) > severity(icon) {
+				icon = ci
+			}
+		}
+		return icon, s == "day", len(icon) > 0
+	}
+	return "", false, false
+}
+
+// Returns the severity of icon `icon`; -1 if it is not known.
+func severity(icon string) int {
+	for i, c := range icons {
+		if c == base(icon) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns icon `icon` without its -day or -night suffix.
+func base(icon string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(icon, "-day"), "-night")
+}
+
+// Returns the day or the night variant of icon `icon`.
+func variant(icon string, day bool) string {
+	if icon != "clear" && icon != "partly-cloudy" {
+		return icon
+	}
+	if day {
+		return icon + "-day"
+	}
+	return icon + "-night"
+}
@@ -0,0 +1,256 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package weather
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"ricketyspace.net/peach/nws"
+)
+
+// Forecasts from weather.gov, for the US.
+type NWS struct{}
+
+var _ Provider = NWS{}
+
+// See Provider.
+func (NWS) Forecast(ctx context.Context, lat, lng float32) (*Forecast, error, int) {
+	fBundle, nwsErr := nws.GetForecastBundle(ctx, lat, lng)
+	if nwsErr != nil {
+		return nil, nwsErr, nwsErr.Status
+	}
+
+	// Expand the grid data into hourly series.
+	gs := newGridSeries(fBundle.ForecastGrid)
+
+	f := new(Forecast)
+	f.Source = "weather.gov"
+	f.SourceUrl = "https://www.weather.gov"
+	f.Place = fmt.Sprintf("%s, %s",
+		fBundle.Point.Properties.RelativeLocation.Properties.City,
+		fBundle.Point.Properties.RelativeLocation.Properties.State,
+	)
+	f.Office = fBundle.Point.Properties.GridId
+	f.Notices = make([]string, 0)
+	if fBundle.ForecastGridErr != nil {
+		log.Printf("weather: grid data: %v", fBundle.ForecastGridErr)
+		f.Notices = append(f.Notices, "humidity unavailable")
+	}
+	if fBundle.AlertsErr != nil {
+		log.Printf("weather: alerts: %v", fBundle.AlertsErr)
+		f.Notices = append(f.Notices, "alerts unavailable")
+	}
+	f.Now = WeatherNow{
+		Temperature:     fBundle.ForecastHourly.Properties.Periods[0].Temperature,
+		TemperatureUnit: fBundle.ForecastHourly.Properties.Periods[0].TemperatureUnit,
+		Forecast:        fBundle.ForecastHourly.Properties.Periods[0].ShortForecast,
+		WindSpeed:       fBundle.ForecastHourly.Properties.Periods[0].WindSpeed,
+		WindDirection:   fBundle.ForecastHourly.Properties.Periods[0].WindDirection,
+	}
+	f.Now.Humidity, f.Now.Dewpoint = gs.now(f.Now.TemperatureUnit)
+
+	// Use the current observation from the nearest station if it
+	// is fresh. Observation time is shown in the forecast's time zone.
+	loc := time.UTC
+	st, err := time.Parse(time.RFC3339,
+		fBundle.ForecastHourly.Properties.Periods[0].StartTime)
+	if err == nil {
+		loc = st.Location()
+	}
+	observe(&f.Now, fBundle.Observation, loc)
+	f.AsOf = asOf(fBundle, loc)
+
+	// Hourly forecast.
+	for _, period := range fBundle.ForecastHourly.Properties.Periods {
+		t, err := time.Parse(time.RFC3339, period.StartTime)
+		if err != nil {
+			return nil, err, 500
+		}
+		p := WeatherPeriod{
+			Forecast:        period.DetailedForecast,
+			Summary:         period.ShortForecast,
+			Icon:            icon(period.Icon, period.ShortForecast, period.IsDayTime),
+			Daytime:         period.IsDayTime,
+			Time:            t,
+			Hour:            t.Hour(),
+			Temperature:     period.Temperature,
+			TemperatureUnit: period.TemperatureUnit,
+		}
+		gs.fill(&p, t, t.Add(time.Hour))
+		p.FeelsLike = gs.feelsLike(t, t.Add(time.Hour), p.TemperatureUnit)
+		f.Hourly = append(f.Hourly, p)
+	}
+
+	// Day and night forecast.
+	for _, period := range fBundle.Forecast.Properties.Periods {
+		st, err := time.Parse(time.RFC3339, period.StartTime)
+		if err != nil {
+			return nil, err, 500
+		}
+		et, err := time.Parse(time.RFC3339, period.EndTime)
+		if err != nil {
+			return nil, err, 500
+		}
+		p := WeatherPeriod{
+			Name:            period.Name,
+			Forecast:        period.DetailedForecast,
+			Summary:         period.ShortForecast,
+			Icon:            icon(period.Icon, period.ShortForecast, period.IsDayTime),
+			Daytime:         period.IsDayTime,
+			Time:            st,
+			Temperature:     period.Temperature,
+			TemperatureUnit: period.TemperatureUnit,
+		}
+		gs.fill(&p, st, et)
+		if v := period.ProbabilityOfPrecipitation.Value; v != nil {
+			// The chance that the forecast itself gives.
+			p.PrecipitationChance = int(math.Round(*v))
+		}
+		f.Periods = append(f.Periods, p)
+	}
+
+	// Add alerts if they exist.
+	f.Alerts = make([]Alert, 0)
+	if fBundle.Alerts != nil && len(fBundle.Alerts.Features) > 0 {
+		am := make(map[string]bool, 0) // Alerts map.
+		for _, a := range fBundle.Alerts.Features {
+			if _, ok := am[a.Id]; ok {
+				continue // Duplicate; skip.
+			}
+			f.Alerts = append(f.Alerts, Alert{
+				Event:       a.Properties.Event,
+				Severity:    a.Properties.Severity,
+				Description: strings.Split(a.Properties.Description, "\n\n"),
+				Instruction: strings.Split(a.Properties.Instruction, "\n\n"),
+			})
+			am[a.Id] = true
+		}
+	}
+	return f, nil, 200
+}
+
+// Returns the time the oldest stale part of forecast bundle `b`
+// expired, in location `loc`; nil if no part of it is stale.
+func asOf(b *nws.ForecastBundle, loc *time.Location) *time.Time {
+	stale := []time.Time{b.Forecast.StaleSince, b.ForecastHourly.StaleSince}
+	if b.ForecastGrid != nil {
+		stale = append(stale, b.ForecastGrid.StaleSince)
+	}
+	if b.Alerts != nil {
+		stale = append(stale, b.Alerts.StaleSince)
+	}
+	var oldest *time.Time
+	for i := range stale {
+		if stale[i].IsZero() || oldest != nil && !stale[i].Before(*oldest) {
+			continue
+		}
+		stale[i] = stale[i].In(loc)
+		oldest = &stale[i]
+	}
+	return oldest
+}
+
+// Grid data layers that are shown on the weather periods.
+type gridSeries struct {
+	humidity      *nws.HourlySeries[*float64]
+	dewpoint      *nws.HourlySeries[*float64]
+	dewpointUom   string
+	precipitation *nws.HourlySeries[*float64]
+	apparent      *nws.HourlySeries[*float64]
+	apparentUom   string
+}
+
+// Expands the grid data layers into hourly series. Layers that cannot
+// be expanded are left empty.
+func newGridSeries(g *nws.ForecastGrid) *gridSeries {
+	gs := new(gridSeries)
+	if g == nil {
+		return gs
+	}
+	gs.humidity, _ = g.Properties.RelativeHumidity.Hourly()
+	gs.dewpoint, _ = g.Properties.Dewpoint.Hourly()
+	gs.dewpointUom = g.Properties.Dewpoint.Uom
+	gs.precipitation, _ = g.Properties.ProbabilityOfPrecipitation.Hourly()
+	gs.apparent, _ = g.Properties.ApparentTemperature.Hourly()
+	gs.apparentUom = g.Properties.ApparentTemperature.Uom
+	return gs
+}
+
+// Returns the current humidity and dewpoint; the dewpoint is in
+// temperature unit `unit`.
+func (gs *gridSeries) now(unit string) (int, int) {
+	now := time.Now()
+	h, _ := gs.humidity.At(now)
+	if h == nil || *h < 1 {
+		return 0, 0
+	}
+	d := 0
+	if v, _ := gs.dewpoint.At(now); v != nil {
+		d = temperature(*v, gs.dewpointUom, unit)
+	}
+	return int(math.Round(*h)), d
+}
+
+// Fills in the average humidity, average dewpoint and the highest
+// chance of precipitation in the time from `start` to `end` for
+// weather period `p`.
+func (gs *gridSeries) fill(p *WeatherPeriod, start, end time.Time) {
+	if h, ok := mean(gs.humidity.Between(start, end)); ok && h >= 1 {
+		p.Humidity = int(math.Round(h))
+	}
+	if d, ok := mean(gs.dewpoint.Between(start, end)); ok {
+		p.Dewpoint = temperature(d, gs.dewpointUom, p.TemperatureUnit)
+	}
+	for _, v := range gs.precipitation.Between(start, end) {
+		if v != nil && int(math.Round(*v)) > p.PrecipitationChance {
+			p.PrecipitationChance = int(math.Round(*v))
+		}
+	}
+}
+
+// Returns the average apparent temperature in the time from `start`
+// to `end` in temperature unit `unit`; nil if it is not known.
+func (gs *gridSeries) feelsLike(start, end time.Time, unit string) *int {
+	a, ok := mean(gs.apparent.Between(start, end))
+	if !ok {
+		return nil
+	}
+	t := temperature(a, gs.apparentUom, unit)
+	return &t
+}
+
+// Returns the mean of the non-nil values. The second return value is
+// false if there are no non-nil values.
+func mean(values []*float64) (float64, bool) {
+	sum := 0.0
+	n := 0
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		sum += *v
+		n += 1
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// Converts temperature `v` in grid unit of measure `uom` to
+// temperature unit `unit` (F or C).
+func temperature(v float64, uom, unit string) int {
+	if uom == "wmoUnit:degC" && unit == "F" {
+		v = v*9/5 + 32
+	}
+	if uom == "wmoUnit:degF" && unit == "C" {
+		v = (v - 32) * 5 / 9
+	}
+	return int(math.Round(v))
+}
@@ -0,0 +1,98 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package weather
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"ricketyspace.net/peach/nws"
+)
+
+// Observations older than this are stale.
+const staleObservation = 2 * time.Hour
+
+// Compass points, clockwise from north.
+var compass = []string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// Fills `now` with the measurements from observation `o`. The
+// observation time is shown in location `loc`.
+//
+// Returns false and leaves `now` untouched if the observation is
+// missing or stale; measurements that are missing from the
+// observation are left as is.
+func observe(now *WeatherNow, o *nws.Observation, loc *time.Location) bool {
+	if o == nil || o.Properties.Temperature.Value == nil {
+		return false
+	}
+	ts, err := time.Parse(time.RFC3339, o.Properties.Timestamp)
+	if err != nil || time.Since(ts) > staleObservation {
+		return false
+	}
+	p := o.Properties
+
+	ts = ts.In(loc)
+	now.ObservedAt = &ts
+	// Station is a link; keep just the station identifier.
+	now.Station = p.Station[strings.LastIndex(p.Station, "/")+1:]
+	now.Temperature = temperature(*p.Temperature.Value,
+		p.Temperature.UnitCode, now.TemperatureUnit)
+	if len(p.TextDescription) > 0 {
+		now.Forecast = p.TextDescription
+	}
+	if p.WindSpeed.Value != nil {
+		now.WindSpeed = speed(p.WindSpeed)
+		now.WindDirection = ""
+		if p.WindDirection.Value != nil && *p.WindSpeed.Value > 0 {
+			now.WindDirection = direction(*p.WindDirection.Value)
+		}
+	}
+	now.WindGust = ""
+	if p.WindGust.Value != nil {
+		now.WindGust = speed(p.WindGust)
+	}
+	if p.RelativeHumidity.Value != nil {
+		now.Humidity = int(math.Round(*p.RelativeHumidity.Value))
+	}
+	if p.Dewpoint.Value != nil {
+		now.Dewpoint = temperature(*p.Dewpoint.Value,
+			p.Dewpoint.UnitCode, now.TemperatureUnit)
+	}
+	if p.BarometricPressure.Value != nil &&
+		p.BarometricPressure.UnitCode == "wmoUnit:Pa" {
+		now.Pressure = fmt.Sprintf("%.2f inHg",
+			*p.BarometricPressure.Value/3386.389)
+	}
+	if p.Visibility.Value != nil && p.Visibility.UnitCode == "wmoUnit:m" {
+		now.Visibility = strconv.FormatFloat(
+			math.Round(*p.Visibility.Value/1609.344*10)/10,
+			'f', -1, 64) + " mi"
+	}
+	return true
+}
+
+// Formats wind speed `v` in mph.
+func speed(v nws.QuantitativeValue) string {
+	s := *v.Value
+	switch v.UnitCode {
+	case "wmoUnit:km_h-1":
+		s = s / 1.609344
+	case "wmoUnit:m_s-1":
+		s = s * 3600 / 1609.344
+	}
+	return fmt.Sprintf("%d mph", int(math.Round(s)))
+}
+
+// Converts wind direction `degrees` to a compass point.
+func direction(degrees float64) string {
+	degrees = math.Mod(math.Mod(degrees, 360)+360, 360)
+	i := int(math.Round(degrees/22.5)) % len(compass)
+	return compass[i]
+}
@@ -0,0 +1,235 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package weather
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"ricketyspace.net/peach/openmeteo"
+)
+
+// Forecasts from Open-Meteo, for anywhere.
+type OpenMeteo struct{}
+
+var _ Provider = OpenMeteo{}
+
+// Day periods start at this hour and night periods end at it.
+const dayStart = 6
+
+// Night periods start at this hour and day periods end at it.
+const nightStart = 18
+
+// WMO weather codes that Open-Meteo uses, and their descriptions.
+var weatherCodes = map[int]string{
+	0:  "Clear",
+	1:  "Mostly Clear",
+	2:  "Partly Cloudy",
+	3:  "Cloudy",
+	45: "Fog",
+	48: "Freezing Fog",
+	51: "Light Drizzle",
+	53: "Drizzle",
+	55: "Heavy Drizzle",
+	56: "Light Freezing Drizzle",
+	57: "Freezing Drizzle",
+	61: "Light Rain",
+	63: "Rain",
+	65: "Heavy Rain",
+	66: "Light Freezing Rain",
+	67: "Freezing Rain",
+	71: "Light Snow",
+	73: "Snow",
+	75: "Heavy Snow",
+	77: "Snow Grains",
+	80: "Light Rain Showers",
+	81: "Rain Showers",
+	82: "Heavy Rain Showers",
+	85: "Snow Showers",
+	86: "Heavy Snow Showers",
+	95: "Thunderstorms",
+	96: "Thunderstorms With Hail",
+	99: "Thunderstorms With Heavy Hail",
+}
+
+// See Provider.
+func (OpenMeteo) Forecast(ctx context.Context, lat, lng float32) (*Forecast, error, int) {
+	om, err := openmeteo.GetForecast(ctx, lat, lng)
+	if err != nil {
+		log.Printf("weather: open-meteo: %v", err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("open-meteo: %v", ctx.Err()), 504
+		}
+		return nil, fmt.Errorf("open-meteo: %v", err), 502
+	}
+	loc := om.Location()
+
+	f := new(Forecast)
+	f.Source = "open-meteo.com"
+	f.SourceUrl = "https://open-meteo.com"
+	f.Notices = []string{"alerts unavailable"}
+	f.Alerts = make([]Alert, 0)
+
+	c := om.Current
+	f.Now = WeatherNow{
+		Temperature:     int(math.Round(c.Temperature)),
+		TemperatureUnit: "F",
+		Forecast:        describe(c.WeatherCode, c.IsDay == 1),
+		WindSpeed:       fmt.Sprintf("%d mph", int(math.Round(c.WindSpeed))),
+		Humidity:        int(math.Round(c.RelativeHumidity)),
+		Dewpoint:        int(math.Round(c.Dewpoint)),
+	}
+	if c.WindSpeed >= 0.5 {
+		f.Now.WindDirection = direction(c.WindDirection)
+	}
+
+	// Hourly forecast, from the current hour.
+	h := om.Hourly
+	now := time.Now()
+	codes := []int{} // Weather code of each hour.
+	for i, ts := range h.Time {
+		t := time.Unix(ts, 0).In(loc)
+		if t.Add(time.Hour).Before(now) {
+			continue
+		}
+		day := at(h.IsDay, i) == 1
+		summary := describe(at(h.WeatherCode, i), day)
+		codes = append(codes, at(h.WeatherCode, i))
+		var feelsLike *int
+		if i < len(h.ApparentTemperature) {
+			a := int(math.Round(h.ApparentTemperature[i]))
+			feelsLike = &a
+		}
+		f.Hourly = append(f.Hourly, WeatherPeriod{
+			Forecast:            summary,
+			Summary:             summary,
+			Icon:                icon("", summary, day),
+			Daytime:             day,
+			Time:                t,
+			Hour:                t.Hour(),
+			Temperature:         int(math.Round(at(h.Temperature, i))),
+			TemperatureUnit:     "F",
+			FeelsLike:           feelsLike,
+			Humidity:            int(math.Round(at(h.RelativeHumidity, i))),
+			Dewpoint:            int(math.Round(at(h.Dewpoint, i))),
+			PrecipitationChance: int(math.Round(at(h.PrecipitationProbability, i))),
+		})
+	}
+	f.Periods = dayNight(f.Hourly, codes, now.In(loc))
+	return f, nil, 200
+}
+
+// Splits the hourly forecast `hourly`, whose weather codes are
+// `codes`, into day and night periods named in the manner of the NWS
+// forecast; for instance, Tonight and Tuesday Night. `now` is the
+// current time in the forecast's time zone.
+//
+// A day period has the highest temperature in it and a night period
+// the lowest; the forecast of a period is that of its most severe
+// weather, which has the highest weather code.
+func dayNight(hourly []WeatherPeriod, codes []int, now time.Time) []WeatherPeriod {
+	periods := []WeatherPeriod{}
+	start := 0
+	for start < len(hourly) {
+		first := hourly[start]
+		night := first.Hour < dayStart || first.Hour >= nightStart
+		end := start
+		code := -1
+		p := WeatherPeriod{
+			Time:            first.Time,
+			Temperature:     first.Temperature,
+			TemperatureUnit: first.TemperatureUnit,
+		}
+		humidity, dewpoint := 0, 0
+		for ; end < len(hourly); end++ {
+			h := hourly[end]
+			if end > start && (h.Hour == dayStart || h.Hour == nightStart) {
+				break // Next period.
+			}
+			if night && h.Temperature < p.Temperature ||
+				!night && h.Temperature > p.Temperature {
+				p.Temperature = h.Temperature
+			}
+			if h.PrecipitationChance > p.PrecipitationChance {
+				p.PrecipitationChance = h.PrecipitationChance
+			}
+			humidity += h.Humidity
+			dewpoint += h.Dewpoint
+			if codes[end] > code {
+				code = codes[end]
+			}
+		}
+		n := float64(end - start)
+		p.Humidity = int(math.Round(float64(humidity) / n))
+		p.Dewpoint = int(math.Round(float64(dewpoint) / n))
+		p.Name = periodName(first.Time, now, night)
+		p.Summary = describe(code, !night)
+		p.Icon = icon("", p.Summary, !night)
+		p.Daytime = !night
+		p.Forecast = detail(p.Summary, p, night)
+		periods = append(periods, p)
+		start = end
+	}
+	return periods
+}
+
+// Returns the name of the period that starts at `t`; for instance,
+// Today, Tonight, Tuesday or Tuesday Night.
+func periodName(t, now time.Time, night bool) string {
+	// Hours after midnight belong to the night before.
+	day := t.Add(-dayStart * time.Hour)
+	now = now.Add(-dayStart * time.Hour)
+	today := day.Year() == now.Year() && day.YearDay() == now.YearDay()
+	switch {
+	case today && night:
+		return "Tonight"
+	case today:
+		return "Today"
+	case night:
+		return day.Weekday().String() + " Night"
+	}
+	return day.Weekday().String()
+}
+
+// Returns the forecast of period `p`, whose weather is `weather`, in
+// the manner of the NWS forecast; for instance, Partly Cloudy, with a
+// high near 75.
+func detail(weather string, p WeatherPeriod, night bool) string {
+	s := fmt.Sprintf("%s, with a high near %d.", weather, p.Temperature)
+	if night {
+		s = fmt.Sprintf("%s, with a low around %d.", weather, p.Temperature)
+	}
+	if p.PrecipitationChance >= 20 {
+		s += fmt.Sprintf(" Chance of precipitation is %d%%.",
+			p.PrecipitationChance)
+	}
+	return s
+}
+
+// Returns the description of WMO weather code `code`. Clear skies are
+// sunny during the day.
+func describe(code int, day bool) string {
+	switch {
+	case code == 0 && day:
+		return "Sunny"
+	case code == 1 && day:
+		return "Mostly Sunny"
+	}
+	if d, ok := weatherCodes[code]; ok {
+		return d
+	}
+	return "Unknown"
+}
+
+// Returns `values[i]`; the zero value if `values` is short.
+func at[T any](values []T, i int) T {
+	var zero T
+	if i >= len(values) {
+		return zero
+	}
+	return values[i]
+}
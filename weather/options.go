@@ -0,0 +1,111 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package weather
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"ricketyspace.net/peach/units"
+)
+
+// Defaults and limits of the options.
+const (
+	defaultStep  = 2
+	maxStep      = 24
+	defaultHours = 12
+	maxHours     = 156 // Length of the NWS hourly forecast.
+	defaultDays  = 4
+	maxDays      = 7
+)
+
+// Options of the weather page.
+type Options struct {
+	Units units.System // Defaults to units.US.
+	Step  int          // Hours in each period of the hourly timeline.
+	Hours int          // Hours in the hourly timeline.
+	Days  int          // Days in the day and night timeline.
+}
+
+// Returns the options in query `q`; for instance, `?units=si`,
+// `?step=3&hours=48` or `?days=7`. Options that are not in the query
+// are set to their defaults.
+func ParseOptions(q url.Values) (Options, error) {
+	o := Options{}
+	s, err := units.ParseSystem(q.Get("units"))
+	if err != nil {
+		return o, err
+	}
+	o.Units = s
+	for _, f := range []struct {
+		name     string
+		v        *int
+		def, max int
+	}{
+		{"step", &o.Step, defaultStep, maxStep},
+		{"hours", &o.Hours, defaultHours, maxHours},
+		{"days", &o.Days, defaultDays, maxDays},
+	} {
+		*f.v = f.def
+		if len(q.Get(f.name)) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(q.Get(f.name))
+		if err != nil || n < 1 || n > f.max {
+			return o, fmt.Errorf("%s %q is not between 1 and %d",
+				f.name, q.Get(f.name), f.max)
+		}
+		*f.v = n
+	}
+	return o, nil
+}
+
+// Returns the options with the ones that are not set, or out of
+// bounds, set to their defaults.
+func (o Options) withDefaults() Options {
+	if len(o.Units) == 0 {
+		o.Units = units.US
+	}
+	if o.Step < 1 || o.Step > maxStep {
+		o.Step = defaultStep
+	}
+	if o.Hours < 1 || o.Hours > maxHours {
+		o.Hours = defaultHours
+	}
+	if o.Days < 1 || o.Days > maxDays {
+		o.Days = defaultDays
+	}
+	return o
+}
+
+// Returns the query of the options that are not set to their
+// defaults; the inverse of ParseOptions.
+func (o Options) Query() url.Values {
+	o = o.withDefaults()
+	q := url.Values{}
+	if o.Units != units.US {
+		q.Set("units", string(o.Units))
+	}
+	if o.Step != defaultStep {
+		q.Set("step", strconv.Itoa(o.Step))
+	}
+	if o.Hours != defaultHours {
+		q.Set("hours", strconv.Itoa(o.Hours))
+	}
+	if o.Days != defaultDays {
+		q.Set("days", strconv.Itoa(o.Days))
+	}
+	return q
+}
+
+// Returns true if the hourly timeline is longer than by default.
+func (o Options) MoreHours() bool {
+	return o.Hours > defaultHours
+}
+
+// Returns true if the day and night timeline is the full week.
+func (o Options) FullWeek() bool {
+	return o.Days >= maxDays
+}
@@ -0,0 +1,90 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package weather
+
+import (
+	"context"
+	"time"
+
+	"ricketyspace.net/peach/cache"
+	"ricketyspace.net/peach/geo"
+)
+
+// A source of forecasts.
+type Provider interface {
+	// Returns the forecast for coordinates `lat`,`lng`. The error is
+	// returned along with the HTTP status that it maps to.
+	Forecast(ctx context.Context, lat, lng float32) (*Forecast, error, int)
+}
+
+// A forecast from a Provider; the data that the weather page is made
+// of. Temperatures are in °F and wind speeds in mph.
+type Forecast struct {
+	Source    string // Name of the provider; for instance, weather.gov.
+	SourceUrl string
+	Place     string // Town nearest to the forecast point; may be empty.
+	Office    string // NWS forecast office; empty for other providers.
+	Now       WeatherNow
+	Hourly    []WeatherPeriod // Hourly forecast, from the current hour.
+	Periods   []WeatherPeriod // Day and night forecast.
+	Alerts    []Alert
+	Notices   []string   // Parts of the forecast that are unavailable.
+	AsOf      *time.Time // See Weather.AsOf.
+}
+
+// A rectangle of coordinates.
+type bounds struct {
+	minLat, maxLat, minLng, maxLng float32
+}
+
+// Rectangles that the NWS forecast area is within. They also take in
+// parts of Canada and Mexico.
+var nwsBounds = []bounds{
+	{24, 50, -125.5, -66.5},      // Contiguous US.
+	{51, 72, -180, -129},         // Alaska.
+	{51, 55, 172, 180},           // Western Aleutians.
+	{18.5, 22.5, -161, -154.5},   // Hawaii.
+	{17.5, 18.6, -67.5, -64.5},   // Puerto Rico and the Virgin Islands.
+	{13, 21, 144, 146.5},         // Guam and the Northern Marianas.
+	{-14.6, -14, -171.2, -168.1}, // American Samoa.
+}
+
+// Coordinates in nwsBounds that NWS has no forecast for.
+var notNWS = cache.NewBounded[string, bool](4096, 0, nil)
+
+// How long coordinates are remembered in notNWS.
+const notNWSExpiry = 24 * time.Hour
+
+// A Provider that routes each request by its coordinates: to US for
+// coordinates in the NWS forecast area and to Global for the rest.
+type Router struct {
+	US     Provider
+	Global Provider // May be nil.
+}
+
+// See Provider.
+func (r Router) Forecast(ctx context.Context, lat, lng float32) (*Forecast, error, int) {
+	ll := geo.FormatLatLng(lat, lng)
+	if r.Global == nil || inNWS(lat, lng) && !notNWS.Get(ll) {
+		f, err, status := r.US.Forecast(ctx, lat, lng)
+		if status != 404 || r.Global == nil {
+			return f, err, status
+		}
+		// Not in the US after all; for instance, Toronto.
+		notNWS.Set(ll, true, time.Now().Add(notNWSExpiry))
+	}
+	return r.Global.Forecast(ctx, lat, lng)
+}
+
+// Returns true if coordinates `lat`,`lng` may be in the NWS forecast
+// area.
+func inNWS(lat, lng float32) bool {
+	for _, b := range nwsBounds {
+		if lat >= b.minLat && lat <= b.maxLat &&
+			lng >= b.minLng && lng <= b.maxLng {
+			return true
+		}
+	}
+	return false
+}
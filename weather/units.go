@@ -0,0 +1,104 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package weather
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ricketyspace.net/peach/units"
+)
+
+// Temperatures in forecast prose; for instance, high near 86 or heat
+// index values as high as 101. The third group is set when the number
+// is not a temperature; for instance, gusts as high as 20 mph or snow
+// accumulation of around 1 to 2 inches.
+var proseTemperatureRegex = regexp.MustCompile(`\b(near|around|as high as|as low as) (-?[0-9]+(?:\.[0-9]+)?)((?: to -?[0-9]+(?:\.[0-9]+)?)?(?: (?:mph|inch(?:es)?|miles?|percent)\b|%))?`)
+
+// Wind speeds in forecast prose; for instance, 5 to 10 mph.
+var proseSpeedRegex = regexp.MustCompile(`\b[0-9]+(?: to [0-9]+)? mph\b`)
+
+// Amounts of precipitation in forecast prose; for instance, 1 to 3
+// inches, between 1 and 2 inches or 0.5 inch.
+var proseAmountRegex = regexp.MustCompile(`\b([0-9]+(?:\.[0-9]+)?)(?: (to|and) ([0-9]+(?:\.[0-9]+)?))? inch(?:es)?\b`)
+
+// Converts the measurements in the weather page from the US units
+// that providers forecast in to the units of system `s`.
+func (w *Weather) convert(s units.System) {
+	w.Units = s
+	u := s.Units()
+	if u == units.US.Units() {
+		return
+	}
+	n := &w.Now
+	from := n.TemperatureUnit
+	n.Temperature = units.Temperature(n.Temperature, from, u.Temperature)
+	n.Dewpoint = units.Temperature(n.Dewpoint, from, u.Temperature)
+	n.TemperatureUnit = u.Temperature
+	n.WindSpeed = units.ConvertString(n.WindSpeed, u.Speed)
+	n.WindGust = units.ConvertString(n.WindGust, u.Speed)
+	n.Pressure = units.ConvertString(n.Pressure, u.Pressure)
+	n.Visibility = units.ConvertString(n.Visibility, u.Distance)
+
+	for _, periods := range [][]WeatherPeriod{
+		w.Q2HTimeline.Periods,
+		w.BiDailyTimeline.Periods,
+		w.hourly,
+	} {
+		for i := range periods {
+			convertPeriod(&periods[i], u)
+		}
+	}
+	for i := range w.Daily {
+		d := &w.Daily[i]
+		for _, t := range []*int{d.High, d.Low} {
+			if t != nil {
+				*t = units.Temperature(*t, d.TemperatureUnit, u.Temperature)
+			}
+		}
+		d.TemperatureUnit = u.Temperature
+	}
+}
+
+// Converts the measurements in period `p` to units `u`.
+func convertPeriod(p *WeatherPeriod, u units.Units) {
+	from := p.TemperatureUnit
+	p.Temperature = units.Temperature(p.Temperature, from, u.Temperature)
+	p.Dewpoint = units.Temperature(p.Dewpoint, from, u.Temperature)
+	if p.FeelsLike != nil {
+		// Not converted in place; periods may share it.
+		t := units.Temperature(*p.FeelsLike, from, u.Temperature)
+		p.FeelsLike = &t
+	}
+	p.TemperatureUnit = u.Temperature
+	p.Forecast = convertProse(p.Forecast, from, u)
+}
+
+// Converts the measurements in forecast prose `s`, whose temperatures
+// are in unit `from`, to units `u`.
+func convertProse(s, from string, u units.Units) string {
+	s = proseTemperatureRegex.ReplaceAllStringFunc(s, func(m string) string {
+		g := proseTemperatureRegex.FindStringSubmatch(m)
+		if len(g[3]) > 0 {
+			return m
+		}
+		t, err := strconv.Atoi(g[2])
+		if err != nil {
+			return m
+		}
+		return g[1] + " " + strconv.Itoa(units.Temperature(t, from, u.Temperature))
+	})
+	s = proseSpeedRegex.ReplaceAllStringFunc(s, func(m string) string {
+		return units.ConvertString(m, u.Speed)
+	})
+	return proseAmountRegex.ReplaceAllStringFunc(s, func(m string) string {
+		g := proseAmountRegex.FindStringSubmatch(m)
+		if len(g[2]) == 0 {
+			return units.ConvertString(g[1]+" in", u.Precipitation)
+		}
+		c := units.ConvertString(g[1]+" to "+g[3]+" in", u.Precipitation)
+		return strings.Replace(c, " to ", " "+g[2]+" ", 1)
+	})
+}
@@ -4,161 +4,254 @@
 package weather
 
 import (
+	"context"
 	"fmt"
+	"html/template"
+	"log"
+	"math"
 	"strings"
 	"time"
 
-	"ricketyspace.net/peach/nws"
-	"ricketyspace.net/peach/photon"
-	t "ricketyspace.net/peach/time"
+	"ricketyspace.net/peach/chart"
+	"ricketyspace.net/peach/geo"
+	"ricketyspace.net/peach/units"
 	"ricketyspace.net/peach/version"
 )
 
+// Weather view model. The json field names are part of the
+// /api/v1 interface; do not rename them.
 type Weather struct {
-	Title           string
-	Version         string
-	Location        string
-	Now             WeatherNow
-	Q2HTimeline     WeatherTimeline // Q2H forecast of the next 12 hours.
-	BiDailyTimeline WeatherTimeline // BiDaily forecast for the next 3 days.
-	SearchEnabled   bool
-	Alerts          []Alert
+	Title           string          `json:"-"`
+	Version         string          `json:"-"`
+	Canonical       string          `json:"-"` // Canonical URL path of the page.
+	Location        string          `json:"location"`
+	Office          string          `json:"office"` // NWS forecast office; empty outside the US.
+	Source          string          `json:"source"` // Provider of the forecast; for instance, weather.gov.
+	SourceUrl       string          `json:"-"`
+	Units           units.System    `json:"units"` // System of units of the measurements.
+	Options         Options         `json:"-"`
+	Now             WeatherNow      `json:"now"`
+	Q2HTimeline     WeatherTimeline `json:"q2hTimeline"`     // Hourly forecast; every 2 hours for the next 12 hours by default.
+	BiDailyTimeline WeatherTimeline `json:"biDailyTimeline"` // Day and night forecast; for the next 4 days by default.
+	Daily           []WeatherDay    `json:"daily"`           // Summary of each day in BiDailyTimeline.
+	SearchEnabled   bool            `json:"-"`
+	Chart           template.HTML   `json:"-"` // SVG chart of the next 48 hours.
+	Alerts          []Alert         `json:"alerts"`
+	Notices         []string        `json:"notices"` // Parts of the forecast that are unavailable.
+
+	// Set when weather.gov could not be reached and parts of the
+	// forecast are stale copies; the time the oldest copy expired.
+	AsOf *time.Time `json:"asOf,omitempty"`
+
+	hourly []WeatherPeriod // Hours in the chart.
 }
 
 type WeatherNow struct {
-	Temperature     int
-	TemperatureUnit string
-	Forecast        string
-	WindSpeed       string
-	WindDirection   string
-	Humidity        int
+	Temperature     int    `json:"temperature"`
+	TemperatureUnit string `json:"temperatureUnit"`
+	Forecast        string `json:"forecast"`
+	WindSpeed       string `json:"windSpeed"`
+	WindDirection   string `json:"windDirection"`
+	Humidity        int    `json:"humidity"`
+	Dewpoint        int    `json:"dewpoint"`
+
+	// Measured at the nearest station. ObservedAt is nil and these
+	// are empty when the values above are from the forecast.
+	WindGust   string     `json:"windGust,omitempty"`
+	Pressure   string     `json:"pressure,omitempty"`
+	Visibility string     `json:"visibility,omitempty"`
+	Station    string     `json:"station,omitempty"`
+	ObservedAt *time.Time `json:"observedAt,omitempty"`
 }
 
 type WeatherPeriod struct {
-	Name            string
-	Forecast        string
-	Hour            int
-	Temperature     int
-	TemperatureUnit string
+	Name            string    `json:"name"`
+	Forecast        string    `json:"forecast"`
+	Summary         string    `json:"summary"` // Short forecast; for instance, Partly Sunny.
+	Icon            string    `json:"icon"`    // See icons.
+	Daytime         bool      `json:"-"`
+	Time            time.Time `json:"-"` // Start of the period, in the forecast's time zone.
+	Hour            int       `json:"hour"`
+	Temperature     int       `json:"temperature"`
+	TemperatureUnit string    `json:"temperatureUnit"`
+	FeelsLike       *int      `json:"feelsLike,omitempty"` // Apparent temperature of an hour; nil if not known.
+
+	// From the grid data. Humidity is zero when the grid data is
+	// not available for the period.
+	Humidity            int `json:"humidity"`
+	Dewpoint            int `json:"dewpoint"`
+	PrecipitationChance int `json:"precipitationChance"`
 }
 
 type WeatherTimeline struct {
-	Periods []WeatherPeriod
+	Periods []WeatherPeriod `json:"periods"`
 }
 
 type Alert struct {
-	Event       string
-	Severity    string
-	Description []string
-	Instruction []string
+	Event       string   `json:"event"`
+	Severity    string   `json:"severity"`
+	Description []string `json:"description"`
+	Instruction []string `json:"instruction"`
 }
 
-func NewWeather(lat, lng float32) (*Weather, error, int) {
-	fBundle, nwsErr := nws.GetForecastBundle(lat, lng)
-	if nwsErr != nil {
-		return nil, nwsErr, nwsErr.Status
+// Makes the weather page for coordinates `lat`,`lng` from the
+// forecast of provider `p`. The place is named with geocoder `g`,
+// which may be nil.
+func NewWeather(ctx context.Context, p Provider, g geo.Geocoder, lat, lng float32, o Options) (*Weather, error, int) {
+	// Look up the name of the place while the forecast is fetched.
+	place := make(chan string, 1)
+	go func() {
+		place <- placeName(ctx, g, lat, lng)
+	}()
+
+	f, err, status := p.Forecast(ctx, lat, lng)
+	if err != nil {
+		return nil, err, status
+	}
+	if len(f.Hourly) == 0 {
+		return nil, fmt.Errorf("no hourly forecast for %s",
+			geo.FormatLatLng(lat, lng)), 502
 	}
 
-	// Get humidity from the grid data.
-	h := humidity(fBundle.ForecastGrid)
 	w := new(Weather)
-	w.Location = fmt.Sprintf("%s, %s",
-		strings.ToLower(fBundle.Point.Properties.RelativeLocation.Properties.City),
-		strings.ToLower(fBundle.Point.Properties.RelativeLocation.Properties.State),
-	)
+	w.Location = strings.ToLower(<-place)
+	if len(w.Location) == 0 {
+		// Fall back to the town nearest to the forecast point.
+		w.Location = strings.ToLower(f.Place)
+	}
+	if len(w.Location) == 0 {
+		w.Location = geo.FormatLatLng(lat, lng)
+	}
 	w.Title = w.Location
+	w.Canonical = "/" + geo.FormatLatLng(lat, lng)
+	w.Office = f.Office
+	w.Source = f.Source
+	w.SourceUrl = f.SourceUrl
 	w.Version = version.Version
-	w.Now = WeatherNow{
-		Temperature:     fBundle.ForecastHourly.Properties.Periods[0].Temperature,
-		TemperatureUnit: fBundle.ForecastHourly.Properties.Periods[0].TemperatureUnit,
-		Forecast:        fBundle.ForecastHourly.Properties.Periods[0].ShortForecast,
-		WindSpeed:       fBundle.ForecastHourly.Properties.Periods[0].WindSpeed,
-		WindDirection:   fBundle.ForecastHourly.Properties.Periods[0].WindDirection,
-		Humidity:        h,
-	}
-
-	// Build Q2H timeline for the 12 hours.
-	q2hPeriods := []WeatherPeriod{}
-	max := 6
-	for i, period := range fBundle.ForecastHourly.Properties.Periods {
-		if i%2 != 0 {
-			continue // Take every other period
-		}
-		t, err := time.Parse(time.RFC3339, period.StartTime)
-		if err != nil {
-			return nil, err, 500
-		}
-		p := WeatherPeriod{
-			Forecast:        period.DetailedForecast,
-			Hour:            t.Hour(),
-			Temperature:     period.Temperature,
-			TemperatureUnit: period.TemperatureUnit,
-		}
-		q2hPeriods = append(q2hPeriods, p)
-		if len(q2hPeriods) == max {
-			break
-		}
+	w.Notices = f.Notices
+	if w.Notices == nil {
+		w.Notices = make([]string, 0)
+	}
+	w.Now = f.Now
+	w.AsOf = f.AsOf
+
+	o = o.withDefaults()
+	w.Options = o
+
+	// Build the hourly timeline.
+	hourly := f.Hourly
+	if len(hourly) > o.Hours {
+		hourly = hourly[:o.Hours]
 	}
 	w.Q2HTimeline = WeatherTimeline{
-		Periods: q2hPeriods,
-	}
-
-	// Build BiDaily  timeline for the next 3 days.
-	bdPeriods := []WeatherPeriod{}
-	max = 8
-	for _, period := range fBundle.Forecast.Properties.Periods {
-		p := WeatherPeriod{
-			Name:            period.Name,
-			Forecast:        period.DetailedForecast,
-			Temperature:     period.Temperature,
-			TemperatureUnit: period.TemperatureUnit,
-		}
-		bdPeriods = append(bdPeriods, p)
-		if len(bdPeriods) == max {
-			break
-		}
+		Periods: timeline(hourly, o.Step, (o.Hours+o.Step-1)/o.Step),
 	}
+
+	// Build the day and night timeline.
+	bdPeriods := dayPeriods(f.Periods, o.Days)
 	w.BiDailyTimeline = WeatherTimeline{
 		Periods: bdPeriods,
 	}
-	w.SearchEnabled = photon.Enabled()
+	w.Daily = daily(bdPeriods)
+	w.SearchEnabled = g != nil
 
-	// Add alerts if they exist.
-	if len(fBundle.Alerts.Features) > 0 {
+	w.Alerts = f.Alerts
+	if w.Alerts == nil {
 		w.Alerts = make([]Alert, 0)
-		am := make(map[string]bool, 0) // Alerts map.
-		for _, f := range fBundle.Alerts.Features {
-			if _, ok := am[f.Id]; ok {
-				continue // Duplicate; skip.
+	}
+
+	w.hourly = append([]WeatherPeriod{}, f.Hourly[:chartHours(f.Hourly)]...)
+
+	w.convert(o.Units)
+	w.Chart = chart.Render(w.chartHours(), w.Now.TemperatureUnit)
+	return w, nil, 200
+}
+
+// Returns up to `max` periods of `step` hours each from `hourly`. A
+// period has the temperature and forecast of its first hour, the
+// average humidity and dewpoint of its hours and the highest chance
+// of precipitation in them.
+func timeline(hourly []WeatherPeriod, step, max int) []WeatherPeriod {
+	periods := []WeatherPeriod{}
+	for i := 0; i < len(hourly) && len(periods) < max; i += step {
+		end := i + step
+		if end > len(hourly) {
+			end = len(hourly)
+		}
+		p := hourly[i]
+		humidity, dewpoint := 0, 0
+		n := 0
+		for _, h := range hourly[i:end] {
+			if h.Humidity > 0 {
+				humidity += h.Humidity
+				dewpoint += h.Dewpoint
+				n += 1
 			}
-			a := Alert{
-				Event:       f.Properties.Event,
-				Severity:    f.Properties.Severity,
-				Description: strings.Split(f.Properties.Description, "\n\n"),
-				Instruction: strings.Split(f.Properties.Instruction, "\n\n"),
+			if h.PrecipitationChance > p.PrecipitationChance {
+				p.PrecipitationChance = h.PrecipitationChance
 			}
-			w.Alerts = append(w.Alerts, a)
-			am[f.Id] = true
 		}
+		if n > 0 {
+			p.Humidity = int(math.Round(float64(humidity) / float64(n)))
+			p.Dewpoint = int(math.Round(float64(dewpoint) / float64(n)))
+		}
+		periods = append(periods, p)
 	}
-	return w, nil, 200
+	return periods
+}
+
+// Returns the link to the weather page with option `name` set to
+// `value`; the option is set to its default if `value` is empty. The
+// other options are kept.
+func (w *Weather) Link(name, value string) string {
+	q := w.Options.Query()
+	q.Set(name, value)
+	o, err := ParseOptions(q)
+	if err != nil {
+		o = w.Options
+	}
+	if query := o.Query().Encode(); len(query) > 0 {
+		return w.Canonical + "?" + query
+	}
+	return w.Canonical
 }
 
-func humidity(g *nws.ForecastGrid) int {
+// Hours in the chart.
+const maxChartHours = 48
+
+// Returns the number of hours in `hourly` that are in the chart.
+func chartHours(hourly []WeatherPeriod) int {
+	if len(hourly) > maxChartHours {
+		return maxChartHours
+	}
+	return len(hourly)
+}
+
+// Returns the hours of the chart.
+func (w *Weather) chartHours() []chart.Hour {
+	hours := []chart.Hour{}
+	for _, h := range w.hourly {
+		hours = append(hours, chart.Hour{
+			Time:                h.Time,
+			Temperature:         h.Temperature,
+			FeelsLike:           h.FeelsLike,
+			PrecipitationChance: h.PrecipitationChance,
+			Day:                 h.Daytime,
+		})
+	}
+	return hours
+}
+
+// Returns the name of the place at `lat`,`lng` from geocoder `g`; an
+// empty string if `g` is nil or the place is not known.
+func placeName(ctx context.Context, g geo.Geocoder, lat, lng float32) string {
 	if g == nil {
-		return 0
+		return ""
 	}
-	for _, v := range g.Properties.RelativeHumidity.Values {
-		if v.Value < 1 {
-			continue
-		}
-		yes, err := t.IsCurrent(v.ValidTime)
-		if err != nil {
-			return 0
-		}
-		if yes {
-			return v.Value
-		}
+	c, err := g.Reverse(ctx, lat, lng)
+	if err != nil {
+		log.Printf("weather: %v", err)
+		return ""
 	}
-	return 0
+	return c.Name
 }
@@ -0,0 +1,825 @@
+// Copyright © 2022 siddharth ravikumar <s@ricketyspace.net>
+// SPDX-License-Identifier: ISC
+
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"ricketyspace.net/peach/nws"
+	"ricketyspace.net/peach/units"
+)
+
+// A Provider that returns a fixed forecast, or an error with status
+// `status`, and counts its requests.
+type fakeProvider struct {
+	source   string
+	status   int
+	requests int
+}
+
+func (p *fakeProvider) Forecast(ctx context.Context, lat, lng float32) (*Forecast, error, int) {
+	p.requests += 1
+	if p.status != 200 {
+		return nil, fmt.Errorf("%s: no forecast", p.source), p.status
+	}
+	return &Forecast{Source: p.source}, nil, 200
+}
+
+func TestRouter(t *testing.T) {
+	us := &fakeProvider{source: "us", status: 200}
+	global := &fakeProvider{source: "global", status: 200}
+	r := Router{US: us, Global: global}
+
+	for _, c := range [][2]float32{
+		{41.1145, -83.178},   // Tiffin, OH.
+		{61.2181, -149.9003}, // Anchorage, AK.
+		{21.3069, -157.8583}, // Honolulu, HI.
+		{18.4655, -66.1057},  // San Juan, PR.
+		{13.4443, 144.7937},  // Hagåtña, Guam.
+	} {
+		f, err, _ := r.Forecast(context.Background(), c[0], c[1])
+		if err != nil || f.Source != "us" {
+			t.Errorf("router: %v: %v %v", c, f, err)
+			return
+		}
+	}
+	for _, c := range [][2]float32{
+		{52.52, 13.405},      // Berlin.
+		{-33.8688, 151.2093}, // Sydney.
+		{19.4326, -99.1332},  // Mexico City.
+	} {
+		f, err, _ := r.Forecast(context.Background(), c[0], c[1])
+		if err != nil || f.Source != "global" {
+			t.Errorf("router: %v: %v %v", c, f, err)
+			return
+		}
+	}
+
+	// Toronto is in the bounds of the contiguous US, but NWS has no
+	// forecast for it.
+	us.status = 404
+	us.requests = 0
+	for i := 0; i < 2; i++ {
+		f, err, _ := r.Forecast(context.Background(), 43.6532, -79.3832)
+		if err != nil || f.Source != "global" {
+			t.Errorf("router: toronto: %v %v", f, err)
+			return
+		}
+	}
+	if us.requests != 1 {
+		t.Errorf("router: toronto: us requests: %v", us.requests)
+		return
+	}
+
+	// Errors other than 404 are not routed to the global provider.
+	us.status = 503
+	_, err, status := r.Forecast(context.Background(), 41.1145, -83.178)
+	if err == nil || status != 503 {
+		t.Errorf("router: 503: %v %v", err, status)
+		return
+	}
+
+	// Without a global provider.
+	us.status = 404
+	r.Global = nil
+	_, err, status = r.Forecast(context.Background(), 52.52, 13.405)
+	if err == nil || status != 404 {
+		t.Errorf("router: no global: %v %v", err, status)
+		return
+	}
+}
+
+// Starts a fake Open-Meteo server whose forecast starts at midnight
+// UTC today and is `hours` long.
+//
+// Temperatures are 50°F plus the hour of the day; it rains at 14:00.
+func fakeOpenMeteo(hours int) *httptest.Server {
+	midnight := time.Now().UTC().Truncate(24 * time.Hour)
+	type hourly struct {
+		Time                     []int64   `json:"time"`
+		Temperature              []float64 `json:"temperature_2m"`
+		ApparentTemperature      []float64 `json:"apparent_temperature"`
+		RelativeHumidity         []float64 `json:"relative_humidity_2m"`
+		Dewpoint                 []float64 `json:"dew_point_2m"`
+		PrecipitationProbability []float64 `json:"precipitation_probability"`
+		WeatherCode              []int     `json:"weather_code"`
+		IsDay                    []int     `json:"is_day"`
+	}
+	h := hourly{}
+	for i := 0; i < hours; i++ {
+		t := midnight.Add(time.Duration(i) * time.Hour)
+		h.Time = append(h.Time, t.Unix())
+		h.Temperature = append(h.Temperature, float64(50+t.Hour()))
+		h.ApparentTemperature = append(h.ApparentTemperature, float64(48+t.Hour()))
+		h.RelativeHumidity = append(h.RelativeHumidity, 60)
+		h.Dewpoint = append(h.Dewpoint, 40)
+		code, pop := 2, 10.0
+		if t.Hour() == 14 {
+			code, pop = 61, 70
+		}
+		h.WeatherCode = append(h.WeatherCode, code)
+		h.PrecipitationProbability = append(h.PrecipitationProbability, pop)
+		day := 0
+		if t.Hour() >= dayStart && t.Hour() < nightStart {
+			day = 1
+		}
+		h.IsDay = append(h.IsDay, day)
+	}
+	body, _ := json.Marshal(map[string]any{
+		"latitude":              52.52,
+		"longitude":             13.42,
+		"utc_offset_seconds":    0,
+		"timezone":              "UTC",
+		"timezone_abbreviation": "UTC",
+		"current": map[string]any{
+			"time":                 time.Now().Unix(),
+			"temperature_2m":       64.6,
+			"relative_humidity_2m": 55,
+			"dew_point_2m":         47.7,
+			"weather_code":         0,
+			"wind_speed_10m":       7.6,
+			"wind_direction_10m":   225,
+			"is_day":               1,
+		},
+		"hourly": h,
+	})
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+}
+
+func TestOpenMeteo(t *testing.T) {
+	ts := fakeOpenMeteo(72)
+	defer ts.Close()
+	os.Setenv("PEACH_OPENMETEO_URL", ts.URL)
+	defer os.Unsetenv("PEACH_OPENMETEO_URL")
+
+	w, err, _ := NewWeather(context.Background(), OpenMeteo{}, nil, 52.52, 13.405, Options{})
+	if err != nil {
+		t.Errorf("weather: %v", err)
+		return
+	}
+	if w.Location != "52.5200,13.4050" || w.Source != "open-meteo.com" ||
+		len(w.Office) > 0 {
+		t.Errorf("weather: %v %v %v", w.Location, w.Source, w.Office)
+		return
+	}
+	if w.Now.Temperature != 65 || w.Now.Forecast != "Sunny" ||
+		w.Now.WindSpeed != "8 mph" || w.Now.WindDirection != "SW" ||
+		w.Now.Humidity != 55 || w.Now.Dewpoint != 48 {
+		t.Errorf("weather: now: %v", w.Now)
+		return
+	}
+	if len(w.Notices) != 1 || w.Notices[0] != "alerts unavailable" {
+		t.Errorf("weather: notices: %v", w.Notices)
+		return
+	}
+
+	// The hourly forecast starts at the current hour.
+	q2h := w.Q2HTimeline.Periods
+	if len(q2h) != 6 || q2h[0].Hour != time.Now().UTC().Hour() ||
+		q2h[1].Hour != (q2h[0].Hour+2)%24 {
+		t.Errorf("weather: q2h: %v", q2h)
+		return
+	}
+	if q2h[0].Temperature != 50+q2h[0].Hour || q2h[0].Humidity != 60 ||
+		q2h[0].Dewpoint != 40 || q2h[0].FeelsLike == nil ||
+		*q2h[0].FeelsLike != 48+q2h[0].Hour {
+		t.Errorf("weather: q2h: %v", q2h[0])
+		return
+	}
+
+	// The chart is of the next 48 hours, or what is left of the
+	// forecast.
+	if len(w.hourly) != chartHours(w.hourly) || len(w.hourly) < 24 ||
+		!strings.Contains(string(w.Chart), "feels like") {
+		t.Errorf("weather: chart: %v %v", len(w.hourly), w.Chart)
+		return
+	}
+
+	// Daily summary; it rains at 14:00 each day. The night of the last
+	// day is cut short.
+	if len(w.Daily) < 3 {
+		t.Errorf("weather: daily: %v", w.Daily)
+		return
+	}
+	for _, d := range w.Daily[1 : len(w.Daily)-1] {
+		if d.High == nil || *d.High != 67 || d.PrecipitationChance != 70 ||
+			d.Condition != "Light Rain" || d.Icon != "rain" {
+			t.Errorf("weather: daily: %v", d)
+			return
+		}
+		if d.Low != nil && *d.Low != 50 {
+			t.Errorf("weather: daily: low: %v", *d.Low)
+			return
+		}
+	}
+
+	// Day and night periods.
+	bd := w.BiDailyTimeline.Periods
+	if len(bd) < 4 {
+		t.Errorf("weather: bi-daily: %v", bd)
+		return
+	}
+	if bd[0].Name != "Today" && bd[0].Name != "Tonight" {
+		t.Errorf("weather: bi-daily: first: %v", bd[0].Name)
+		return
+	}
+	for _, p := range bd[1:] {
+		if p.Hour != 0 || p.Time.Hour() != dayStart && p.Time.Hour() != nightStart {
+			t.Errorf("weather: bi-daily: %v", p)
+			return
+		}
+		if p.Time.Hour() == dayStart {
+			// High at 17:00; rain at 14:00.
+			if p.Temperature != 67 || p.PrecipitationChance != 70 ||
+				!strings.HasPrefix(p.Forecast, "Light Rain, with a high near 67.") {
+				t.Errorf("weather: bi-daily: day: %v", p)
+				return
+			}
+			if p.Name != p.Time.Weekday().String() {
+				t.Errorf("weather: bi-daily: day name: %v", p.Name)
+				return
+			}
+		} else if p.Time.Add(12 * time.Hour).Before(midnightAfter(72)) {
+			// Low at midnight.
+			if p.Temperature != 50 || p.Forecast != "Partly Cloudy, with a low around 50." {
+				t.Errorf("weather: bi-daily: night: %v", p)
+				return
+			}
+			if p.Name != p.Time.Weekday().String()+" Night" && p.Name != "Tonight" {
+				t.Errorf("weather: bi-daily: night name: %v", p.Name)
+				return
+			}
+		}
+	}
+}
+
+func TestOpenMeteoOptions(t *testing.T) {
+	ts := fakeOpenMeteo(72)
+	defer ts.Close()
+	os.Setenv("PEACH_OPENMETEO_URL", ts.URL)
+	defer os.Unsetenv("PEACH_OPENMETEO_URL")
+
+	o := Options{Step: 3, Hours: 24, Days: 7}
+	w, err, _ := NewWeather(context.Background(), OpenMeteo{}, nil, 52.52, 13.405, o)
+	if err != nil {
+		t.Errorf("weather: %v", err)
+		return
+	}
+	q := w.Q2HTimeline.Periods
+	if len(q) != 8 || q[1].Hour != (q[0].Hour+3)%24 {
+		t.Errorf("weather: hourly: %v", q)
+		return
+	}
+	if len(w.BiDailyTimeline.Periods) != len(w.Daily)*2 &&
+		len(w.BiDailyTimeline.Periods) != len(w.Daily)*2-1 {
+		t.Errorf("weather: bi-daily: %v %v", len(w.BiDailyTimeline.Periods),
+			len(w.Daily))
+		return
+	}
+	if w.Options.Units != units.US || !w.Options.FullWeek() {
+		t.Errorf("weather: options: %v", w.Options)
+		return
+	}
+
+	// Hours that are past the forecast.
+	o = Options{Step: 1, Hours: 156, Days: 1}
+	w, err, _ = NewWeather(context.Background(), OpenMeteo{}, nil, 52.52, 13.405, o)
+	if err != nil {
+		t.Errorf("weather: %v", err)
+		return
+	}
+	if n := len(w.Q2HTimeline.Periods); n < 49 || n > 72 {
+		t.Errorf("weather: hourly: %v", n)
+		return
+	}
+	if len(w.Daily) != 1 || len(w.BiDailyTimeline.Periods) < 1 ||
+		len(w.BiDailyTimeline.Periods) > 2 {
+		t.Errorf("weather: bi-daily: %v %v", w.BiDailyTimeline.Periods, w.Daily)
+		return
+	}
+}
+
+// Returns the end of the fake Open-Meteo forecast that is `hours`
+// long.
+func midnightAfter(hours int) time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour).Add(time.Duration(hours) * time.Hour)
+}
+
+func TestTimeline(t *testing.T) {
+	hourly := []WeatherPeriod{
+		{Hour: 1, Temperature: 60, Humidity: 50, Dewpoint: 40, PrecipitationChance: 10},
+		{Hour: 2, Temperature: 61, Humidity: 61, Dewpoint: 43, PrecipitationChance: 30},
+		{Hour: 3, Temperature: 62},
+		{Hour: 4, Temperature: 63, Humidity: 70, Dewpoint: 50},
+		{Hour: 5, Temperature: 64},
+	}
+	periods := timeline(hourly, 2, 6)
+	if len(periods) != 3 {
+		t.Errorf("timeline: %v", periods)
+		return
+	}
+	p := periods[0]
+	if p.Hour != 1 || p.Temperature != 60 || p.Humidity != 56 ||
+		p.Dewpoint != 42 || p.PrecipitationChance != 30 {
+		t.Errorf("timeline: %v", p)
+		return
+	}
+	p = periods[1]
+	if p.Hour != 3 || p.Humidity != 70 || p.Dewpoint != 50 {
+		t.Errorf("timeline: %v", p)
+		return
+	}
+	if periods[2].Hour != 5 {
+		t.Errorf("timeline: %v", periods[2])
+		return
+	}
+	if periods = timeline(hourly, 1, 2); len(periods) != 2 {
+		t.Errorf("timeline: max: %v", periods)
+		return
+	}
+}
+
+func TestConvert(t *testing.T) {
+	feelsLike := 23
+	w := &Weather{
+		Now: WeatherNow{
+			Temperature:     75,
+			TemperatureUnit: "F",
+			WindSpeed:       "5 to 10 mph",
+			Dewpoint:        50,
+			WindGust:        "20 mph",
+			Pressure:        "30.01 inHg",
+			Visibility:      "10 mi",
+		},
+		Q2HTimeline: WeatherTimeline{
+			Periods: []WeatherPeriod{
+				{Temperature: 32, TemperatureUnit: "F", Dewpoint: 23,
+					FeelsLike: &feelsLike},
+			},
+		},
+		BiDailyTimeline: WeatherTimeline{
+			Periods: []WeatherPeriod{
+				{
+					Temperature:     86,
+					TemperatureUnit: "F",
+					Forecast: "Sunny, with a high near 86. Southwest wind 5 to 10 mph, " +
+						"with gusts as high as 20 mph. Heat index values as high as 95. " +
+						"New snow accumulation of 1 to 3 inches possible.",
+				},
+				{
+					Temperature:     25,
+					TemperatureUnit: "F",
+					Forecast: "Snow. Cloudy, with a low around 25. Wind chill values as low as -5. " +
+						"Chance of precipitation is 100%. " +
+						"New snow accumulation of around 2 inches possible.",
+				},
+				{
+					Temperature:     45,
+					TemperatureUnit: "F",
+					Forecast: "Rain. High near 45. Chance of precipitation is near 100 percent. " +
+						"New rainfall amounts between 1 and 2 inches possible, " +
+						"locally around 1 to 3 inches or as high as 0.5 inch an hour.",
+				},
+			},
+		},
+	}
+	high, low := 86, 32
+	w.Daily = []WeatherDay{{High: &high, Low: &low, TemperatureUnit: "F"}}
+	w.convert(units.SI)
+	if d := w.Daily[0]; *d.High != 30 || *d.Low != 0 || d.TemperatureUnit != "C" {
+		t.Errorf("convert: daily: %v", d)
+		return
+	}
+	n := w.Now
+	if w.Units != units.SI || n.Temperature != 24 || n.TemperatureUnit != "C" ||
+		n.Dewpoint != 10 || n.WindSpeed != "8 to 16 km/h" ||
+		n.WindGust != "32 km/h" || n.Pressure != "1016 hPa" ||
+		n.Visibility != "16.1 km" {
+		t.Errorf("convert: now: %v", n)
+		return
+	}
+	p := w.Q2HTimeline.Periods[0]
+	if p.Temperature != 0 || p.Dewpoint != -5 || p.TemperatureUnit != "C" ||
+		*p.FeelsLike != -5 || feelsLike != 23 {
+		t.Errorf("convert: q2h: %v", p)
+		return
+	}
+	p = w.BiDailyTimeline.Periods[0]
+	e := "Sunny, with a high near 30. Southwest wind 8 to 16 km/h, " +
+		"with gusts as high as 32 km/h. Heat index values as high as 35. " +
+		"New snow accumulation of 25 to 76 mm possible."
+	if p.Temperature != 30 || p.Forecast != e {
+		t.Errorf("convert: bi-daily: %v", p)
+		return
+	}
+	p = w.BiDailyTimeline.Periods[1]
+	e = "Snow. Cloudy, with a low around -4. Wind chill values as low as -21. " +
+		"Chance of precipitation is 100%. " +
+		"New snow accumulation of around 51 mm possible."
+	if p.Forecast != e {
+		t.Errorf("convert: snow: %q", p.Forecast)
+		return
+	}
+	p = w.BiDailyTimeline.Periods[2]
+	e = "Rain. High near 7. Chance of precipitation is near 100 percent. " +
+		"New rainfall amounts between 25 and 51 mm possible, " +
+		"locally around 25 to 76 mm or as high as 13 mm an hour."
+	if p.Forecast != e {
+		t.Errorf("convert: rain: %q", p.Forecast)
+		return
+	}
+
+	// Mixed units.
+	w = &Weather{Now: WeatherNow{Temperature: 75, TemperatureUnit: "F",
+		WindSpeed: "10 mph", Pressure: "30.01 inHg", Visibility: "10 mi"}}
+	w.convert(units.UK)
+	n = w.Now
+	if n.Temperature != 24 || n.WindSpeed != "10 mph" ||
+		n.Pressure != "1016 hPa" || n.Visibility != "10 mi" {
+		t.Errorf("convert: uk: %v", n)
+		return
+	}
+
+	// US units are left as is.
+	w = &Weather{Now: WeatherNow{Temperature: 75, TemperatureUnit: "F",
+		Pressure: "30.01 inHg"}}
+	w.convert(units.US)
+	if w.Units != units.US || w.Now.Temperature != 75 ||
+		w.Now.Pressure != "30.01 inHg" {
+		t.Errorf("convert: us: %v", w.Now)
+		return
+	}
+}
+
+func TestIcon(t *testing.T) {
+	for _, c := range []struct {
+		link, forecast string
+		day            bool
+		e              string
+	}{
+		{"https://api.weather.gov/icons/land/day/few?size=medium", "Sunny", true, "clear-day"},
+		{"https://api.weather.gov/icons/land/night/sct?size=medium", "Partly Cloudy", true, "partly-cloudy-night"},
+		{"https://api.weather.gov/icons/land/day/rain_showers,30/tsra_hi,40?size=medium", "", true, "thunderstorm"},
+		{"https://api.weather.gov/icons/land/day/bkn/rain,60?size=medium", "", true, "rain"},
+		{"https://api.weather.gov/icons/land/night/fzra,80?size=medium", "", false, "sleet"},
+		{"https://api.weather.gov/icons/land/day/new_code?size=medium", "Chance Light Snow", true, "snow"},
+		{"", "Patchy Freezing Fog", true, "fog"},
+		{"", "Mostly Sunny", true, "partly-cloudy-day"},
+		{"", "Mostly Cloudy", false, "cloudy"},
+		{"", "Sunny", true, "clear-day"},
+		{"", "Clear", false, "clear-night"},
+		{"", "Light Rain Showers", true, "rain"},
+	} {
+		if i := icon(c.link, c.forecast, c.day); i != c.e {
+			t.Errorf("icon: %q %q: %v", c.link, c.forecast, i)
+			return
+		}
+	}
+}
+
+func TestDaily(t *testing.T) {
+	periods := []WeatherPeriod{
+		{Name: "Tonight", Temperature: 60, TemperatureUnit: "F",
+			Summary: "Mostly Clear", Icon: "partly-cloudy-night"},
+		{Name: "Monday", Daytime: true, Temperature: 80, TemperatureUnit: "F",
+			PrecipitationChance: 20, Summary: "Partly Sunny", Icon: "partly-cloudy-day"},
+		{Name: "Monday Night", Temperature: 62, TemperatureUnit: "F",
+			PrecipitationChance: 60, Summary: "Showers Likely", Icon: "rain"},
+		{Name: "Tuesday", Daytime: true, Temperature: 75, TemperatureUnit: "F",
+			Summary: "Thunderstorms", Icon: "thunderstorm"},
+		{Name: "Tuesday Night", Temperature: 58, TemperatureUnit: "F",
+			Summary: "Clear", Icon: "clear-night"},
+		{Name: "Wednesday", Daytime: true, Temperature: 77, TemperatureUnit: "F",
+			Summary: "Sunny", Icon: "clear-day"},
+	}
+	days := daily(periods)
+	if len(days) != 4 {
+		t.Errorf("daily: %v", days)
+		return
+	}
+	d := days[0]
+	if d.Name != "Tonight" || d.High != nil || d.Low == nil || *d.Low != 60 ||
+		d.Icon != "partly-cloudy-night" {
+		t.Errorf("daily: tonight: %v", d)
+		return
+	}
+	d = days[1]
+	if d.Name != "Monday" || *d.High != 80 || *d.Low != 62 ||
+		d.PrecipitationChance != 60 || d.Condition != "Showers Likely" ||
+		d.Icon != "rain" {
+		t.Errorf("daily: monday: %v", d)
+		return
+	}
+	d = days[2]
+	if *d.High != 75 || *d.Low != 58 || d.Condition != "Thunderstorms" ||
+		d.Icon != "thunderstorm" {
+		t.Errorf("daily: tuesday: %v", d)
+		return
+	}
+	d = days[3]
+	if d.Name != "Wednesday" || *d.High != 77 || d.Low != nil ||
+		d.Icon != "clear-day" {
+		t.Errorf("daily: wednesday: %v", d)
+		return
+	}
+
+	// A clear day with a partly cloudy night shows the day icon.
+	days = daily([]WeatherPeriod{
+		{Daytime: true, Summary: "Sunny", Icon: "clear-day"},
+		{Summary: "Partly Cloudy", Icon: "partly-cloudy-night"},
+	})
+	if len(days) != 1 || days[0].Icon != "partly-cloudy-day" ||
+		days[0].Condition != "Partly Cloudy" {
+		t.Errorf("daily: %v", days)
+		return
+	}
+}
+
+func TestDayPeriods(t *testing.T) {
+	periods := []WeatherPeriod{
+		{Name: "Tonight"},
+		{Name: "Monday", Daytime: true},
+		{Name: "Monday Night"},
+		{Name: "Tuesday", Daytime: true},
+		{Name: "Tuesday Night"},
+		{Name: "Wednesday", Daytime: true},
+	}
+	for days, e := range map[int]string{
+		1: "Tonight",
+		2: "Tonight Monday",
+		3: "Tonight Monday Tuesday",
+		4: "Tonight Monday Tuesday Wednesday",
+		7: "Tonight Monday Tuesday Wednesday",
+	} {
+		bd := dayPeriods(periods, days)
+		names := []string{}
+		for _, d := range daily(bd) {
+			names = append(names, d.Name)
+		}
+		if strings.Join(names, " ") != e {
+			t.Errorf("day periods: %d: %v", days, names)
+			return
+		}
+	}
+
+	// Starting with a day period.
+	if bd := dayPeriods(periods[1:], 1); len(bd) != 2 || bd[1].Name != "Monday Night" {
+		t.Errorf("day periods: today: %v", bd)
+		return
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	o, err := ParseOptions(url.Values{})
+	if err != nil || o.Units != units.US || o.Step != 2 || o.Hours != 12 ||
+		o.Days != 4 || o.MoreHours() || o.FullWeek() {
+		t.Errorf("parse options: %v %v", o, err)
+		return
+	}
+	o, err = ParseOptions(url.Values{"units": {"si"}, "step": {"3"},
+		"hours": {"156"}, "days": {"7"}})
+	if err != nil || o.Units != units.SI || o.Step != 3 || o.Hours != 156 ||
+		o.Days != 7 || !o.MoreHours() || !o.FullWeek() {
+		t.Errorf("parse options: %v %v", o, err)
+		return
+	}
+	for _, q := range []url.Values{
+		{"units": {"metric"}},
+		{"step": {"0"}},
+		{"step": {"25"}},
+		{"hours": {"157"}},
+		{"hours": {"a"}},
+		{"days": {"8"}},
+		{"days": {"-1"}},
+	} {
+		if _, err := ParseOptions(q); err == nil {
+			t.Errorf("parse options: %v: no error", q)
+			return
+		}
+	}
+}
+
+func TestLink(t *testing.T) {
+	w := &Weather{Canonical: "/41.1150,-83.1770"}
+	w.Options, _ = ParseOptions(url.Values{"units": {"si"}})
+	for _, c := range [][3]string{
+		{"hours", "48", "/41.1150,-83.1770?hours=48&units=si"},
+		{"days", "7", "/41.1150,-83.1770?days=7&units=si"},
+		{"units", "us", "/41.1150,-83.1770"},
+		{"units", "", "/41.1150,-83.1770"},
+		{"days", "4", "/41.1150,-83.1770?units=si"},
+		{"days", "100", "/41.1150,-83.1770?units=si"},
+	} {
+		if l := w.Link(c[0], c[1]); l != c[2] {
+			t.Errorf("link: %s=%s: %v", c[0], c[1], l)
+			return
+		}
+	}
+}
+
+// Returns an observation that was made `ago` ago at KPIT, with
+// temperature `t` in degrees Celsius.
+func observation(ago time.Duration, t *float64) *nws.Observation {
+	v := func(f float64) *float64 { return &f }
+	return &nws.Observation{Properties: nws.ObservationProperties{
+		Station:            "https://api.weather.gov/stations/KPIT",
+		Timestamp:          time.Now().Add(-ago).UTC().Format(time.RFC3339),
+		TextDescription:    "Mostly Cloudy",
+		Temperature:        nws.QuantitativeValue{UnitCode: "wmoUnit:degC", Value: t},
+		Dewpoint:           nws.QuantitativeValue{UnitCode: "wmoUnit:degC", Value: v(15)},
+		WindDirection:      nws.QuantitativeValue{UnitCode: "wmoUnit:degree_(angle)", Value: v(225)},
+		WindSpeed:          nws.QuantitativeValue{UnitCode: "wmoUnit:km_h-1", Value: v(16.09344)},
+		WindGust:           nws.QuantitativeValue{UnitCode: "wmoUnit:m_s-1", Value: v(8.9408)},
+		BarometricPressure: nws.QuantitativeValue{UnitCode: "wmoUnit:Pa", Value: v(101600)},
+		Visibility:         nws.QuantitativeValue{UnitCode: "wmoUnit:m", Value: v(16093.44)},
+		RelativeHumidity:   nws.QuantitativeValue{UnitCode: "wmoUnit:percent", Value: v(61.6)},
+	}}
+}
+
+func TestObserve(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	forecast := WeatherNow{
+		Temperature:     70,
+		TemperatureUnit: "F",
+		Forecast:        "Sunny",
+		WindSpeed:       "5 mph",
+		WindDirection:   "N",
+		Humidity:        50,
+		Dewpoint:        50,
+	}
+	temp := 24.4
+
+	// Fresh observation.
+	now := forecast
+	if !observe(&now, observation(30*time.Minute, &temp), loc) {
+		t.Errorf("observe: fresh: not observed")
+		return
+	}
+	if now.Temperature != 76 || now.TemperatureUnit != "F" ||
+		now.Forecast != "Mostly Cloudy" || now.WindSpeed != "10 mph" ||
+		now.WindDirection != "SW" || now.WindGust != "20 mph" ||
+		now.Humidity != 62 || now.Dewpoint != 59 ||
+		now.Pressure != "30.00 inHg" || now.Visibility != "10 mi" ||
+		now.Station != "KPIT" {
+		t.Errorf("observe: fresh: %+v", now)
+		return
+	}
+	if now.ObservedAt == nil || now.ObservedAt.Location() != loc ||
+		time.Since(*now.ObservedAt) > 31*time.Minute {
+		t.Errorf("observe: fresh: observed at: %v", now.ObservedAt)
+		return
+	}
+
+	// Measurements that are missing are from the forecast.
+	o := observation(30*time.Minute, &temp)
+	o.Properties.WindSpeed.Value = nil
+	o.Properties.WindGust.Value = nil
+	o.Properties.TextDescription = ""
+	now = forecast
+	if !observe(&now, o, loc) || now.Temperature != 76 ||
+		now.WindSpeed != "5 mph" || now.WindDirection != "N" ||
+		now.WindGust != "" || now.Forecast != "Sunny" {
+		t.Errorf("observe: missing: %+v", now)
+		return
+	}
+
+	// No wind direction when it is calm.
+	o = observation(30*time.Minute, &temp)
+	zero := 0.0
+	o.Properties.WindSpeed.Value = &zero
+	now = forecast
+	if !observe(&now, o, loc) || now.WindSpeed != "0 mph" ||
+		now.WindDirection != "" {
+		t.Errorf("observe: calm: %+v", now)
+		return
+	}
+
+	// Stale, missing, without a temperature or a timestamp; the
+	// forecast is left as is.
+	bad := observation(30*time.Minute, &temp)
+	bad.Properties.Timestamp = "yesterday"
+	for name, o := range map[string]*nws.Observation{
+		"stale":          observation(staleObservation+time.Minute, &temp),
+		"missing":        nil,
+		"no temperature": observation(30*time.Minute, nil),
+		"no timestamp":   bad,
+	} {
+		now = forecast
+		if observe(&now, o, loc) || now != forecast {
+			t.Errorf("observe: %s: %+v", name, now)
+			return
+		}
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	v := func(f float64) *float64 { return &f }
+	for _, c := range []struct {
+		v nws.QuantitativeValue
+		e string
+	}{
+		{nws.QuantitativeValue{UnitCode: "wmoUnit:km_h-1", Value: v(16.09344)}, "10 mph"},
+		{nws.QuantitativeValue{UnitCode: "wmoUnit:km_h-1", Value: v(24)}, "15 mph"},
+		{nws.QuantitativeValue{UnitCode: "wmoUnit:m_s-1", Value: v(4.4704)}, "10 mph"},
+		{nws.QuantitativeValue{UnitCode: "wmoUnit:m_s-1", Value: v(0.2)}, "0 mph"},
+		{nws.QuantitativeValue{UnitCode: "wmoUnit:mph", Value: v(7.4)}, "7 mph"},
+	} {
+		if s := speed(c.v); s != c.e {
+			t.Errorf("speed: %v %v: %v", c.v.UnitCode, *c.v.Value, s)
+			return
+		}
+	}
+}
+
+func TestDirection(t *testing.T) {
+	for degrees, e := range map[float64]string{
+		0:      "N",
+		11.2:   "N",
+		11.3:   "NNE",
+		90:     "E",
+		225:    "SW",
+		348.7:  "NNW",
+		348.8:  "N",
+		359:    "N",
+		360:    "N",
+		-45:    "NW",
+		810:    "E",
+		191.25: "SSW",
+	} {
+		if d := direction(degrees); d != e {
+			t.Errorf("direction: %v: %v", degrees, d)
+			return
+		}
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	now := time.Now().UTC().Truncate(time.Second)
+	bundle := func() *nws.ForecastBundle {
+		return &nws.ForecastBundle{
+			Forecast:       &nws.Forecast{},
+			ForecastHourly: &nws.Forecast{},
+			ForecastGrid:   &nws.ForecastGrid{},
+			Alerts:         &nws.FeatureCollection{},
+		}
+	}
+
+	// Fresh.
+	b := bundle()
+	if a := asOf(b, loc); a != nil {
+		t.Errorf("as of: fresh: %v", a)
+		return
+	}
+
+	// Each stale component on its own.
+	for name, stale := range map[string]func(b *nws.ForecastBundle, t time.Time){
+		"forecast": func(b *nws.ForecastBundle, t time.Time) { b.Forecast.StaleSince = t },
+		"hourly":   func(b *nws.ForecastBundle, t time.Time) { b.ForecastHourly.StaleSince = t },
+		"grid":     func(b *nws.ForecastBundle, t time.Time) { b.ForecastGrid.StaleSince = t },
+		"alerts":   func(b *nws.ForecastBundle, t time.Time) { b.Alerts.StaleSince = t },
+	} {
+		b = bundle()
+		stale(b, now.Add(-10*time.Minute))
+		a := asOf(b, loc)
+		if a == nil || !a.Equal(now.Add(-10*time.Minute)) || a.Location() != loc {
+			t.Errorf("as of: %s: %v", name, a)
+			return
+		}
+	}
+
+	// The oldest stale component.
+	b = bundle()
+	b.Forecast.StaleSince = now.Add(-5 * time.Minute)
+	b.ForecastGrid.StaleSince = now.Add(-30 * time.Minute)
+	b.Alerts.StaleSince = now.Add(-20 * time.Minute)
+	if a := asOf(b, loc); a == nil || !a.Equal(now.Add(-30*time.Minute)) {
+		t.Errorf("as of: oldest: %v", a)
+		return
+	}
+
+	// Grid and alerts that could not be fetched.
+	b = bundle()
+	b.ForecastGrid, b.Alerts = nil, nil
+	if a := asOf(b, loc); a != nil {
+		t.Errorf("as of: no grid: %v", a)
+		return
+	}
+	b.ForecastHourly.StaleSince = now.Add(-time.Minute)
+	if a := asOf(b, loc); a == nil || !a.Equal(now.Add(-time.Minute)) {
+		t.Errorf("as of: no grid: hourly: %v", a)
+		return
+	}
+}